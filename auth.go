@@ -0,0 +1,271 @@
+package kubexp
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cached credentials are renewed this long before they expire
+var credentialRefreshMargin = 30 * time.Second
+
+// lifetime of tokens requested for a service account
+var serviceAccountTokenExpirationSeconds = 3600
+
+type authStrategyType struct {
+	name      string
+	usable    func(u userType) bool
+	authorize func(u userType, req *http.Request) error
+}
+
+// authStrategies in order of precedence, the first usable one is taken
+var authStrategies = []authStrategyType{
+	{name: "token",
+		usable: func(u userType) bool { return u.token != "" },
+		authorize: func(u userType, req *http.Request) error {
+			req.Header.Set("Authorization", "Bearer "+u.token)
+			return nil
+		}},
+	{name: "tokenFile",
+		usable: func(u userType) bool { return u.tokenFile != "" },
+		authorize: func(u userType, req *http.Request) error {
+			// read on every request, the file is rotated for projected service account tokens
+			token, err := ioutil.ReadFile(u.tokenFile)
+			if err != nil {
+				return fmt.Errorf("can't read tokenFile of user '%s': %v", u.Name, err)
+			}
+			req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+			return nil
+		}},
+	{name: "clientCertificate",
+		usable: func(u userType) bool { return u.hasClientCertificate() },
+		authorize: func(u userType, req *http.Request) error {
+			// certificate is presented in the tls handshake
+			return nil
+		}},
+	{name: "exec",
+		usable: func(u userType) bool { return u.exec != nil },
+		authorize: func(u userType, req *http.Request) error {
+			return bearerFromProvider(&u.exec.credentialCache, req)
+		}},
+	{name: "basicAuth",
+		usable: func(u userType) bool { return u.username != "" },
+		authorize: func(u userType, req *http.Request) error {
+			req.SetBasicAuth(u.username, u.password)
+			return nil
+		}},
+}
+
+var anonymousAuthStrategy = authStrategyType{name: "anonymous",
+	usable: func(u userType) bool { return true },
+	authorize: func(u userType, req *http.Request) error {
+		return nil
+	}}
+
+// serviceAccountAuthStrategy is opt-in and takes precedence over the kubeconfig credentials, which are only used to request the token
+var serviceAccountAuthStrategy = authStrategyType{name: "serviceAccount",
+	usable: func(u userType) bool { return u.serviceAccount != nil },
+	authorize: func(u userType, req *http.Request) error {
+		return bearerFromProvider(&u.serviceAccount.credentialCache, req)
+	}}
+
+func selectAuthStrategy(u userType) *authStrategyType {
+	if serviceAccountAuthStrategy.usable(u) {
+		return &serviceAccountAuthStrategy
+	}
+	for i := range authStrategies {
+		if authStrategies[i].usable(u) {
+			return &authStrategies[i]
+		}
+	}
+	return &anonymousAuthStrategy
+}
+
+func (u userType) authorize(req *http.Request) error {
+	if u.auth == nil {
+		return nil
+	}
+	return u.auth.authorize(u, req)
+}
+
+func (u userType) authStrategyName() string {
+	if u.auth == nil {
+		return anonymousAuthStrategy.name
+	}
+	if u.auth == &serviceAccountAuthStrategy {
+		return fmt.Sprintf("%s %s/%s", u.auth.name, u.serviceAccount.namespace, u.serviceAccount.name)
+	}
+	return u.auth.name
+}
+
+// invalidate drops cached credentials, e.g. after the api server answered 401
+func (u userType) invalidate() {
+	if u.serviceAccount != nil {
+		u.serviceAccount.invalidate()
+	}
+	if u.exec != nil {
+		u.exec.invalidate()
+	}
+}
+
+func bearerFromProvider(c *credentialCache, req *http.Request) error {
+	cred, err := c.credential()
+	if err != nil {
+		return err
+	}
+	if cred.token != "" {
+		req.Header.Set("Authorization", "Bearer "+cred.token)
+	}
+	return nil
+}
+
+type credentialType struct {
+	token      string
+	clientCert *tls.Certificate
+	expiry     time.Time
+}
+
+func (c *credentialType) expired() bool {
+	return !c.expiry.IsZero() && time.Now().Add(credentialRefreshMargin).After(c.expiry)
+}
+
+type credentialCache struct {
+	mutex sync.Mutex
+	cred  *credentialType
+	fetch func() (*credentialType, error)
+}
+
+func (c *credentialCache) credential() (*credentialType, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.cred != nil && !c.cred.expired() {
+		return c.cred, nil
+	}
+	cred, err := c.fetch()
+	if err != nil {
+		return nil, err
+	}
+	c.cred = cred
+	return cred, nil
+}
+
+func (c *credentialCache) invalidate() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.cred = nil
+}
+
+type execConfigType struct {
+	apiVersion string
+	command    string
+	args       []string
+	env        []string
+}
+
+// execCredentialProvider runs a client.authentication.k8s.io credential plugin and caches its result
+type execCredentialProvider struct {
+	credentialCache
+	config execConfigType
+}
+
+func newExecCredentialProvider(config execConfigType) *execCredentialProvider {
+	p := &execCredentialProvider{config: config}
+	p.fetch = p.run
+	return p
+}
+
+func (p *execCredentialProvider) clientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	cred, err := p.credential()
+	if err != nil {
+		return nil, err
+	}
+	if cred.clientCert == nil {
+		return &tls.Certificate{}, nil
+	}
+	return cred.clientCert, nil
+}
+
+func (p *execCredentialProvider) run() (*credentialType, error) {
+	cmd := execCommand(p.config.command, p.config.args...)
+	execInfo := fmt.Sprintf(`{"apiVersion":"%s","kind":"ExecCredential","spec":{"interactive":false}}`, p.config.apiVersion)
+	cmd.Env = append(append(os.Environ(), p.config.env...), "KUBERNETES_EXEC_INFO="+execInfo)
+	var out, stderr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	tracelog.Printf("running exec credential plugin '%s'", p.config.command)
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("exec credential plugin '%s' failed: %v\n Details: %s", p.config.command, err, stderr.String())
+	}
+	return parseExecCredential(out.Bytes())
+}
+
+func parseExecCredential(b []byte) (*credentialType, error) {
+	var ec struct {
+		Kind   string `json:"kind"`
+		Status struct {
+			Token                 string `json:"token"`
+			ClientCertificateData string `json:"clientCertificateData"`
+			ClientKeyData         string `json:"clientKeyData"`
+			ExpirationTimestamp   string `json:"expirationTimestamp"`
+		} `json:"status"`
+	}
+	if err := json.Unmarshal(b, &ec); err != nil {
+		return nil, fmt.Errorf("can't parse exec credential: %v", err)
+	}
+	if ec.Kind != "ExecCredential" {
+		return nil, fmt.Errorf("exec credential plugin returned kind '%s', expected 'ExecCredential'", ec.Kind)
+	}
+	cred := &credentialType{token: ec.Status.Token}
+	if ec.Status.ClientCertificateData != "" || ec.Status.ClientKeyData != "" {
+		cert, err := tls.X509KeyPair([]byte(ec.Status.ClientCertificateData), []byte(ec.Status.ClientKeyData))
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate in exec credential: %v", err)
+		}
+		cred.clientCert = &cert
+	}
+	if cred.token == "" && cred.clientCert == nil {
+		return nil, fmt.Errorf("exec credential contains neither token nor client certificate")
+	}
+	if ec.Status.ExpirationTimestamp != "" {
+		expiry, err := time.Parse(time.RFC3339, ec.Status.ExpirationTimestamp)
+		if err != nil {
+			return nil, fmt.Errorf("invalid expirationTimestamp in exec credential: %v", err)
+		}
+		cred.expiry = expiry
+	}
+	return cred, nil
+}
+
+// tokenRequestProvider requests tokens for a service account with the TokenRequest api
+type tokenRequestProvider struct {
+	credentialCache
+	namespace, name string
+}
+
+// newTokenRequestProvider uses the credentials of ctx to request tokens for the service account
+func newTokenRequestProvider(ctx contextType, namespace, name string) *tokenRequestProvider {
+	p := &tokenRequestProvider{namespace: namespace, name: name}
+	p.fetch = func() (*credentialType, error) {
+		body := fmt.Sprintf(`{"apiVersion":"authentication.k8s.io/v1","kind":"TokenRequest","spec":{"expirationSeconds":%d}}`, serviceAccountTokenExpirationSeconds)
+		rp, err := newBackend(ctx).restCall(http.MethodPost, "api/v1", fmt.Sprintf("serviceaccounts/%s/token", name), namespace, body)
+		if err != nil {
+			return nil, fmt.Errorf("can't request token for service account '%s/%s': %v", namespace, name, err)
+		}
+		tr := unmarshall(rp)
+		cred := &credentialType{token: configValue(tr, "{{.status.token}}")}
+		if cred.token == "" {
+			return nil, fmt.Errorf("no token for service account '%s/%s' in response", namespace, name)
+		}
+		cred.expiry = totime(configValue(tr, "{{.status.expirationTimestamp}}"))
+		tracelog.Printf("requested token for service account '%s/%s', expires %v", namespace, name, cred.expiry)
+		return cred, nil
+	}
+	return p
+}
@@ -0,0 +1,172 @@
+package kubexp
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testExecProvider(t *testing.T, token string, expiry time.Time) (*execCredentialProvider, string) {
+	callsFile := filepath.Join(t.TempDir(), "calls")
+	plugin, _ := filepath.Abs(filepath.Join("testdata", "exec", "credential-plugin.sh"))
+	env := []string{"CALLS_FILE=" + callsFile, "TEST_TOKEN=" + token}
+	if !expiry.IsZero() {
+		env = append(env, "TEST_EXPIRY="+expiry.UTC().Format(time.RFC3339))
+	}
+	return newExecCredentialProvider(execConfigType{apiVersion: "client.authentication.k8s.io/v1beta1", command: plugin, env: env}), callsFile
+}
+
+func pluginCalls(t *testing.T, callsFile string) []string {
+	b, err := ioutil.ReadFile(callsFile)
+	require.Nil(t, err)
+	return strings.Split(strings.TrimSpace(string(b)), "\n")
+}
+
+func Test_ExecCredentialIsCached(t *testing.T) {
+	require := require.New(t)
+	p, callsFile := testExecProvider(t, "abc", time.Now().Add(time.Hour))
+	for i := 0; i < 3; i++ {
+		cred, err := p.credential()
+		require.Nil(err)
+		require.Equal("abc", cred.token)
+	}
+	calls := pluginCalls(t, callsFile)
+	require.Len(calls, 1)
+	require.Contains(calls[0], `"kind":"ExecCredential"`)
+}
+
+func Test_ExecCredentialRefreshedBeforeExpiry(t *testing.T) {
+	require := require.New(t)
+	p, callsFile := testExecProvider(t, "abc", time.Now().Add(credentialRefreshMargin/2))
+	_, err := p.credential()
+	require.Nil(err)
+	_, err = p.credential()
+	require.Nil(err)
+	require.Len(pluginCalls(t, callsFile), 2)
+}
+
+func Test_ExecCredentialInvalidate(t *testing.T) {
+	require := require.New(t)
+	p, callsFile := testExecProvider(t, "abc", time.Time{})
+	_, err := p.credential()
+	require.Nil(err)
+	p.invalidate()
+	_, err = p.credential()
+	require.Nil(err)
+	require.Len(pluginCalls(t, callsFile), 2)
+}
+
+func Test_ExecCredentialWithoutToken(t *testing.T) {
+	p, _ := testExecProvider(t, "", time.Time{})
+	_, err := p.credential()
+	require.NotNil(t, err)
+}
+
+func Test_ExecCredentialBearerToken(t *testing.T) {
+	require := require.New(t)
+	var auth string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		testAPIHandler(w, r)
+	}))
+	defer server.Close()
+
+	ctx := testContext(server, clusterType{certificateAuthorityData: caDataOf(server)})
+	ctx.user.exec, _ = testExecProvider(t, "tokenFromPlugin", time.Now().Add(time.Hour))
+	ctx.user.auth = selectAuthStrategy(ctx.user)
+	require.Equal("exec", ctx.AuthStrategy())
+	require.Nil(newBackend(ctx).availabiltyCheck())
+	require.Equal("Bearer tokenFromPlugin", auth)
+}
+
+func Test_ParseExecConfig(t *testing.T) {
+	require := require.New(t)
+	c := &configType{configFile: filepath.Join("testdata", "exec", "config")}
+	kc := c.loadKubeconfig()
+	ctx := kc["contexts"].([]interface{})[0].(map[interface{}]interface{})["context"]
+	user := c.parseUser(kc, ctx)
+	require.NotNil(user.exec)
+	require.Equal(filepath.Join("testdata", "exec", "credential-plugin.sh"), user.exec.config.command)
+	require.Equal([]string{"--region", "eu-west-1"}, user.exec.config.args)
+	require.Equal([]string{"TEST_TOKEN=tokenFromPlugin"}, user.exec.config.env)
+
+	os.Setenv("CALLS_FILE", filepath.Join(t.TempDir(), "calls"))
+	defer os.Unsetenv("CALLS_FILE")
+	cred, err := user.exec.credential()
+	require.Nil(err)
+	require.Equal("tokenFromPlugin", cred.token)
+}
+
+func Test_AuthStrategyOrder(t *testing.T) {
+	require := require.New(t)
+	exec := newExecCredentialProvider(execConfigType{command: "true"})
+	require.Equal("anonymous", selectAuthStrategy(userType{}).name)
+	require.Equal("basicAuth", selectAuthStrategy(userType{username: "admin", password: "secret"}).name)
+	require.Equal("exec", selectAuthStrategy(userType{username: "admin", exec: exec}).name)
+	require.Equal("clientCertificate", selectAuthStrategy(userType{clientCertificate: "c.crt", clientKey: "c.key", exec: exec}).name)
+	require.Equal("tokenFile", selectAuthStrategy(userType{tokenFile: "token", clientCertificate: "c.crt", clientKey: "c.key"}).name)
+	require.Equal("token", selectAuthStrategy(userType{token: "abc", tokenFile: "token"}).name)
+	require.Equal("serviceAccount", selectAuthStrategy(userType{token: "abc", serviceAccount: &tokenRequestProvider{}}).name)
+}
+
+func Test_AuthHeaders(t *testing.T) {
+	require := require.New(t)
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.Nil(ioutil.WriteFile(tokenFile, []byte("tokenFromFile\n"), 0600))
+
+	for _, tc := range []struct {
+		user     userType
+		expected string
+	}{
+		{userType{token: "abc"}, "Bearer abc"},
+		{userType{tokenFile: tokenFile}, "Bearer tokenFromFile"},
+		{userType{username: "admin", password: "secret"}, "Basic YWRtaW46c2VjcmV0"},
+		{userType{}, ""},
+	} {
+		tc.user.auth = selectAuthStrategy(tc.user)
+		req, _ := http.NewRequest(http.MethodGet, "https://127.0.0.1/api", nil)
+		require.Nil(tc.user.authorize(req))
+		require.Equal(tc.expected, req.Header.Get("Authorization"), tc.user.authStrategyName())
+	}
+}
+
+func Test_ServiceAccountTokenRequest(t *testing.T) {
+	require := require.New(t)
+	var tokenRequests int
+	var auth []string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = append(auth, r.Header.Get("Authorization"))
+		if r.Method == http.MethodPost && r.URL.Path == "/api/v1/namespaces/dev/serviceaccounts/viewer/token" {
+			tokenRequests++
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"kind":"TokenRequest","status":{"token":"saToken","expirationTimestamp":"` + time.Now().Add(time.Hour).UTC().Format(time.RFC3339) + `"}}`))
+			return
+		}
+		testAPIHandler(w, r)
+	}))
+	defer server.Close()
+
+	ctx := testContext(server, clusterType{certificateAuthorityData: caDataOf(server)})
+	ctx.user = userType{Name: "admin", token: "adminToken"}
+	ctx.user.auth = selectAuthStrategy(ctx.user)
+
+	c := &configType{}
+	_, err := c.withServiceAccount(ctx, "viewer")
+	require.NotNil(err)
+	ctx.user, err = c.withServiceAccount(ctx, "dev/viewer")
+	require.Nil(err)
+	require.Equal("serviceAccount dev/viewer", ctx.AuthStrategy())
+
+	be := newBackend(ctx)
+	require.Nil(be.availabiltyCheck())
+	require.Nil(be.availabiltyCheck())
+	require.Equal(1, tokenRequests)
+	require.Equal([]string{"Bearer adminToken", "Bearer saToken", "Bearer saToken"}, auth)
+}
@@ -4,14 +4,16 @@ import (
 	"bufio"
 	"bytes"
 	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
 	"errors"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"os/exec"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"encoding/json"
@@ -53,10 +55,15 @@ func (s *nameSorterType) getElements() []interface{} {
 }
 
 func (s *nameSorterType) Less(i, j int) bool {
-	iName := fmt.Sprintf("%s/%s", resItemName(s.elements[i]), resItemNamespace(s.elements[i]))
-	jName := fmt.Sprintf("%s/%s", resItemName(s.elements[j]), resItemNamespace(s.elements[j]))
-	res := strings.Compare(iName, jName) < 0
-	return (s.ascending && res) || (!s.ascending && !res)
+	cmp := compareNames(s.elements[i], s.elements[j])
+	return cmp != 0 && (cmp < 0) == s.ascending
+}
+
+// compareNames compares the items by name and namespace, sorters use it as tiebreak so items with equal keys keep their order
+func compareNames(a, b interface{}) int {
+	aName := fmt.Sprintf("%s/%s", resItemName(a), resItemNamespace(a))
+	bName := fmt.Sprintf("%s/%s", resItemName(b), resItemNamespace(b))
+	return strings.Compare(aName, bName)
 }
 
 type timeSorterType struct {
@@ -95,21 +102,20 @@ func (s *timeSorterType) getElements() []interface{} {
 func (s *timeSorterType) Less(i, j int) bool {
 	itimeStr := resItemCreationTimestamp(s.elements[i])
 	jtimeStr := resItemCreationTimestamp(s.elements[j])
-	var res bool
-	if len(itimeStr) > 0 && len(jtimeStr) > 0 && jtimeStr != itimeStr {
-		itime := totime(itimeStr)
-		jtime := totime(jtimeStr)
-		if itime.Equal(jtime) {
-			res = true
-		} else {
-			res = jtime.After(itime)
-		}
-	} else {
-		iName := fmt.Sprintf("%s/%s", resItemName(s.elements[i]), resItemNamespace(s.elements[i]))
-		jName := fmt.Sprintf("%s/%s", resItemName(s.elements[j]), resItemNamespace(s.elements[j]))
-		res = strings.Compare(iName, jName) < 0
+	var cmp int
+	switch {
+	case itimeStr == "" || jtimeStr == "":
+		cmp = strings.Compare(itimeStr, jtimeStr)
+	case totime(itimeStr).Before(totime(jtimeStr)):
+		cmp = -1
+	case totime(itimeStr).After(totime(jtimeStr)):
+		cmp = 1
+	}
+	if cmp == 0 {
+		// items created in the same second, e.g. the pods of a replica set
+		return compareNames(s.elements[i], s.elements[j]) < 0
 	}
-	return (s.ascending && res) || (!s.ascending && !res)
+	return (cmp < 0) == s.ascending
 }
 
 type changedObjType struct {
@@ -117,18 +123,26 @@ type changedObjType struct {
 	changeTime time.Time
 }
 
-type watchType struct {
-	reader *io.ReadCloser
-	online bool
+type sortedItemsType struct {
+	version  int
+	sorter   string
+	selector string
+	items    []interface{}
 }
 
 type backendType struct {
-	context             contextType
-	resItems            map[string][]interface{}
+	context contextType
+	store   *storeType
+	// mutex guards the fields below, they are used by the watches, the update loop and the ui
+	mutex               sync.Mutex
 	podLogs             []byte
 	watches             map[string]*watchType
-	sorter              sorterType
-	restExecutor        func(httpMethod, url, body string, timout int) (*http.Response, error)
+	sortOrders          map[string]sortOrderType
+	sortedItems         map[string]sortedItemsType
+	lastUsed            map[string]time.Time
+	selectors           map[string]selectorType
+	watchRequests       chan resourceType
+	restExecutor        func(httpMethod, url, body string, timout int, header http.Header) (*http.Response, error)
 	updateLoop          <-chan time.Time
 	lastLivenessCheck   time.Time
 	clusterLivenessDone chan bool
@@ -138,50 +152,145 @@ type backendType struct {
 }
 
 func newBackend(context contextType) *backendType {
-	return &backendType{context: context,
-		restExecutor: func(httpMethod, url, body string, timeout int) (*http.Response, error) {
-			if body != "" {
-				tracelog.Printf("body: '%s'", body)
-			}
-			client := &http.Client{
-				Transport: &http.Transport{
-					TLSClientConfig: &tls.Config{
-						InsecureSkipVerify: true,
-					},
-					IdleConnTimeout: 3 * time.Second,
-				},
-			}
-			if timeout > 0 {
-				client.Timeout = time.Duration(timeout) * time.Second
-			}
-			req, err := http.NewRequest(httpMethod, url, strings.NewReader(body))
-			if err != nil {
-				errorlog.Printf("can't create request url: %s, error: %v", url, err)
-				return nil, err
-			}
-			if !strings.HasPrefix(url, "http://127.0.0.1") && !strings.HasPrefix(url, "http://localhost") {
-				req.Header.Set("Authorization", "Bearer "+context.user.token)
-			}
-			if httpMethod == http.MethodPatch {
-				req.Header.Add("Content-Type", "application/strategic-merge-patch+json")
-				req.Header.Add("Accept", "*/*")
-			}
-
-			response, err := client.Do(req)
-			if err == nil {
-				tracelog.Printf("rest call: %s %s , response status: %s", httpMethod, url, response.Status)
-			}
-
-			return response, err
-		},
-
-		sorter:              &nameSorterType{ascending: true},
+	tlsConfig, tlsErr := newTLSConfig(context)
+	if tlsErr != nil {
+		errorlog.Printf("can't create tls config for context '%s': %v", context.Name, tlsErr)
+	}
+	b := &backendType{context: context,
+		store:               newStore(),
+		watches:             map[string]*watchType{},
+		sortOrders:          map[string]sortOrderType{},
+		sortedItems:         map[string]sortedItemsType{},
+		lastUsed:            map[string]time.Time{},
+		selectors:           map[string]selectorType{},
+		watchRequests:       make(chan resourceType, 100),
 		updateLoop:          time.NewTicker(time.Duration(250) * time.Millisecond).C,
 		clusterLivenessDone: make(chan bool),
 		changedObjList:      []changedObjType{},
 		changedObjSet:       map[string]bool{},
 		blink:               true,
 	}
+	b.restExecutor = func(httpMethod, url, body string, timeout int, header http.Header) (*http.Response, error) {
+		// every request of the backend passes here, so none changes the cluster of a read-only context
+		if err := b.checkRequest(httpMethod, url); err != nil {
+			return nil, err
+		}
+		if body != "" {
+			tracelog.Printf("body: '%s'", body)
+		}
+		if tlsErr != nil {
+			return nil, tlsErr
+		}
+		client := &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: tlsConfig,
+				IdleConnTimeout: 3 * time.Second,
+			},
+		}
+		if timeout > 0 {
+			client.Timeout = time.Duration(timeout) * time.Second
+		}
+		req, err := http.NewRequest(httpMethod, url, strings.NewReader(body))
+		if err != nil {
+			errorlog.Printf("can't create request url: %s, error: %v", url, err)
+			return nil, err
+		}
+		if !strings.HasPrefix(url, "http://127.0.0.1") && !strings.HasPrefix(url, "http://localhost") {
+			if err := context.user.authorize(req); err != nil {
+				errorlog.Printf("can't authorize request for context '%s': %v", context.Name, err)
+				return nil, authErrorType{err}
+			}
+		}
+		switch httpMethod {
+		case http.MethodPatch:
+			req.Header.Add("Accept", "*/*")
+		case http.MethodPost, http.MethodPut:
+			req.Header.Add("Content-Type", "application/json")
+		}
+		// e.g. the patch type of a mutation or the table media type of a list
+		for k := range header {
+			req.Header.Set(k, header.Get(k))
+		}
+
+		response, err := client.Do(req)
+		if err == nil {
+			tracelog.Printf("rest call: %s %s , response status: %s", httpMethod, url, response.Status)
+			if response.StatusCode == http.StatusUnauthorized {
+				context.user.invalidate()
+			}
+		}
+
+		return response, err
+	}
+	return b
+}
+
+func newTLSConfig(context contextType) (*tls.Config, error) {
+	cluster := context.Cluster
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: cluster.insecureSkipTLSVerify,
+		ServerName:         cluster.tlsServerName,
+	}
+	if context.user.hasClientCertificate() {
+		cert, err := loadClientCertificate(context.user)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	} else if context.user.exec != nil {
+		tlsConfig.GetClientCertificate = context.user.exec.clientCertificate
+	}
+	if cluster.insecureSkipTLSVerify {
+		warninglog.Printf("tls verification disabled for cluster '%s'", cluster.Name)
+		return tlsConfig, nil
+	}
+	var caData []byte
+	var err error
+	switch {
+	case cluster.certificateAuthorityData != "":
+		caData, err = base64.StdEncoding.DecodeString(cluster.certificateAuthorityData)
+		if err != nil {
+			return nil, fmt.Errorf("can't decode certificate-authority-data of cluster '%s': %v", cluster.Name, err)
+		}
+	case cluster.certificateAuthority != "":
+		caData, err = ioutil.ReadFile(cluster.certificateAuthority)
+		if err != nil {
+			return nil, fmt.Errorf("can't read certificate-authority of cluster '%s': %v", cluster.Name, err)
+		}
+	default:
+		// no ca configured, verify against the system roots
+		return tlsConfig, nil
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caData) {
+		return nil, fmt.Errorf("no valid certificates in certificate-authority of cluster '%s'", cluster.Name)
+	}
+	tlsConfig.RootCAs = pool
+	return tlsConfig, nil
+}
+
+func loadClientCertificate(user userType) (tls.Certificate, error) {
+	certPem, err := pemData(user.clientCertificateData, user.clientCertificate)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("can't load client-certificate of user '%s': %v", user.Name, err)
+	}
+	keyPem, err := pemData(user.clientKeyData, user.clientKey)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("can't load client-key of user '%s': %v", user.Name, err)
+	}
+	cert, err := tls.X509KeyPair(certPem, keyPem)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("invalid client certificate of user '%s': %v", user.Name, err)
+	}
+	return cert, nil
+}
+
+// pemData prefers the inlined base64 data over the file reference
+func pemData(data, file string) ([]byte, error) {
+	if data != "" {
+		return base64.StdEncoding.DecodeString(data)
+	}
+	return ioutil.ReadFile(file)
 }
 
 type sorterType interface {
@@ -195,33 +304,182 @@ type sorterType interface {
 	getAscending() bool
 }
 
+// resourceItems returns the sorted items of the resource in namespace ns, they are only sorted again when the items or the sorter changed.
+// The returned slice is shared and must not be modified
 func (b *backendType) resourceItems(ns string, rt resourceType) []interface{} {
-	var r []interface{}
-	if rt.Namespace && ns != "*ALL*" {
-		for _, ri := range b.resItems[rt.Name] {
-			if resItemNamespace(ri) == ns {
-				r = append(r, ri)
+	b.useWatch(rt)
+	if !rt.Namespace {
+		ns = "*ALL*"
+	}
+	key := storeKey(ns, rt.key())
+	b.mutex.Lock()
+	order := b.sortOrders[rt.key()]
+	sel := b.selectors[rt.key()]
+	if cached, ok := b.sortedItems[key]; ok && cached.sorter == order.String() && cached.selector == sel.String() && cached.version == b.store.version(rt.key()) {
+		b.mutex.Unlock()
+		return cached.items
+	}
+	b.mutex.Unlock()
+	r, version := b.store.snapshot(rt.key(), ns)
+	if !sel.isEmpty() {
+		selected := []interface{}{}
+		for _, ri := range r {
+			if sel.matches(ri) {
+				selected = append(selected, ri)
 			}
 		}
-	} else {
-		r = b.resItems[rt.Name]
+		r = selected
 	}
-	b.sorter.setElements(r)
-	sort.Sort(b.sorter)
-	ele := b.sorter.getElements()
+	// sorted without the lock, a column sorter executes the list view, whose functions use the backend
+	sorter := order.newSorter()
+	sorter.setElements(r)
+	sort.Sort(sorter)
+	ele := sorter.getElements()
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.sortedItems[key] = sortedItemsType{version: version, sorter: order.String(), selector: sel.String(), items: ele}
 	return ele
 }
 
+func (b *backendType) sortOrder(resKey string) sortOrderType {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.sortOrders[resKey]
+}
+
+// setSortOrder the items of the resource are sorted in this order, until it is changed again
+func (b *backendType) setSortOrder(resKey string, order sortOrderType) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.sortOrders[resKey] = order
+}
+
+func (b *backendType) selector(resKey string) selectorType {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.selectors[resKey]
+}
+
+// setSelector filters the items of the resource, with serverSelector its watches are stopped. They are started with the selector when the resource is used next time
+func (b *backendType) setSelector(resKey string, sel selectorType) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if sel.isEmpty() {
+		delete(b.selectors, resKey)
+	} else {
+		b.selectors[resKey] = sel
+	}
+	if serverSelector {
+		b.stopWatches0(resKey)
+	}
+}
+
+func (b *backendType) watchOf(name string) *watchType {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.watches[name]
+}
+
+// setWatch registers the watch, a watch registered before under the name is closed, so it doesn't keep writing to the store
+func (b *backendType) setWatch(name string, w *watchType) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if old, ok := b.watches[name]; ok && old != w {
+		old.close()
+	}
+	b.watches[name] = w
+}
+
+func (b *backendType) removeWatch(key string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	delete(b.watches, key)
+}
+
+// watchState of a resource: the state of its watches, in the order offline, loading, online and forbidden. Loading if it isn't watched yet
+func (b *backendType) watchState(resKey string) string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	found := false
+	states := map[string]bool{}
+	for _, w := range b.watches {
+		if w.resKey == resKey {
+			found = true
+			states[w.getState()] = true
+		}
+	}
+	if !found {
+		return watchLoading
+	}
+	for _, state := range []string{watchOffline, watchLoading, watchOnline} {
+		if states[state] {
+			return state
+		}
+	}
+	return watchForbidden
+}
+
+// listProgress items of the resource fetched so far by the lists in progress, total is 0 unless the api server estimated it for all of them
+func (b *backendType) listProgress(resKey string) (fetched, total int) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	estimated := true
+	for _, w := range b.watches {
+		if w.resKey != resKey {
+			continue
+		}
+		f, t := w.getProgress()
+		if f == 0 {
+			continue
+		}
+		fetched += f
+		total += t
+		estimated = estimated && t > 0
+	}
+	if !estimated {
+		total = 0
+	}
+	return fetched, total
+}
+
+// isEmpty true if the resource is watched and has no items in namespace ns. Resources which are not watched yet may have items
+func (b *backendType) isEmpty(ns string, rt resourceType) bool {
+	if b.watchState(rt.key()) != watchOnline {
+		return false
+	}
+	if !rt.Namespace {
+		ns = "*ALL*"
+	}
+	items, _ := b.store.snapshot(rt.key(), ns)
+	return len(items) == 0
+}
+
 func (b *backendType) createWatches(resources []resourceType) error {
-	b.resItems = map[string][]interface{}{}
+	b.store.reset()
+	b.mutex.Lock()
 	b.watches = map[string]*watchType{}
+	b.sortedItems = map[string]sortedItemsType{}
+	b.lastUsed = map[string]time.Time{}
+	b.mutex.Unlock()
+	// namespaces and custom resource definitions are always watched, other resources when they are used
 	for _, res := range resources {
-		if res.Watch {
-			err := b.watch(res.APIPrefix, res.Name)
-			if err != nil {
+		switch res.key() {
+		case namespacesKey:
+			b.mutex.Lock()
+			b.lastUsed[res.key()] = time.Now()
+			b.mutex.Unlock()
+			if err := b.watch(res); err != nil {
 				return err
 			}
-			time.Sleep(50 * time.Millisecond)
+		case crdKey:
+			b.mutex.Lock()
+			b.lastUsed[res.key()] = time.Now()
+			b.mutex.Unlock()
+			go func(res resourceType) {
+				if err := b.watch(res); err != nil {
+					errorlog.Printf("can't watch resource %s: %v", res.Name, err)
+				}
+			}(res)
 		}
 	}
 	go func() {
@@ -233,20 +491,33 @@ func (b *backendType) createWatches(resources []resourceType) error {
 					b.lastLivenessCheck = now
 					err := b.availabiltyCheck()
 					if err != nil {
+						b.mutex.Lock()
 						for _, w := range b.watches {
-							w.online = false
+							w.setOnline(false)
 						}
+						b.mutex.Unlock()
+						b.context.health.set(contextUnreachable, err)
 						errorlog.Printf("cluster heart beat not ok: %v", err)
+					} else {
+						b.context.health.set(contextReachable, nil)
 					}
 				}
 
 				b.updateChangedObjList()
-				b.blink = !b.blink
+				b.stopIdleWatches()
 
 				if currentState.name == "browseState" {
 					updateResourceItemList(false)
 				}
 
+			case rt := <-b.watchRequests:
+				infolog.Printf("start watching resource %s", rt.Name)
+				go func() {
+					if err := b.watch(rt); err != nil {
+						errorlog.Printf("can't watch resource %s: %v", rt.Name, err)
+					}
+				}()
+
 			case <-b.clusterLivenessDone:
 				infolog.Printf("clusterHeartbeatDone")
 				return
@@ -258,6 +529,9 @@ func (b *backendType) createWatches(resources []resourceType) error {
 }
 
 func (b *backendType) updateChangedObjList() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.blink = !b.blink
 	var k = 0
 	for i, el := range b.changedObjList {
 		chgTime := el.changeTime
@@ -275,13 +549,34 @@ func (b *backendType) updateChangedObjList() {
 	b.changedObjList = newList
 }
 
+func (b *backendType) markChanged(id string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.changedObjList = append(b.changedObjList, changedObjType{id: id, changeTime: time.Now()})
+	b.changedObjSet[id] = true
+}
+
+func (b *backendType) isChanged(id string) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.changedObjSet[id]
+}
+
+func (b *backendType) blinkOn() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.blink
+}
+
 func (b *backendType) closeWatches0(filter func(string) bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
 	tracelog.Printf("close watches %s", b.watches)
 	if b.watches != nil {
 		for k, v := range b.watches {
 			if filter(k) {
 				tracelog.Printf("Close watch %s", k)
-				(*v.reader).Close()
+				v.close()
 			}
 		}
 	}
@@ -294,33 +589,121 @@ func (b *backendType) closeWatches() {
 	b.clusterLivenessDone <- true
 }
 
-func (b *backendType) delete(ns string, resource resourceType, resourceItem string, noGracePeriod bool) (interface{}, error) {
-	var rc string
-	var err error
-	queryParam := ""
+// mutationType request changing a resource item, it can be sent as server side dry run to preview its result
+type mutationType struct {
+	method    string
+	resource  resourceType
+	namespace string
+	// name of the item, empty when it is created
+	name  string
+	query string
+	body  string
+	// patchType content type of the body of a patch
+	patchType string
+}
+
+// patch types of the mutations, custom resources support no strategic merge patches
+const (
+	strategicMergePatchType = "application/strategic-merge-patch+json"
+	mergePatchType          = "application/merge-patch+json"
+)
+
+// mutate sends the mutation, with dryRun the api server validates it and returns the result without persisting it
+func (b *backendType) mutate(m mutationType, dryRun bool) (string, error) {
+	ress := m.resource.Name
+	if m.name != "" {
+		ress = fmt.Sprintf("%s/%s", ress, m.name)
+	}
+	query := m.query
+	if dryRun {
+		query = strings.TrimPrefix(query+"&"+dryRunQuery, "&")
+	}
+	if query != "" {
+		ress = fmt.Sprintf("%s?%s", ress, query)
+	}
+	url := fmt.Sprintf("%s/%s/%s", b.context.Cluster.URL, m.resource.APIPrefix, ress)
+	if m.resource.Namespace {
+		url = fmt.Sprintf("%s/%s/namespaces/%s/%s", b.context.Cluster.URL, m.resource.APIPrefix, m.namespace, ress)
+	}
+	var header http.Header
+	if m.patchType != "" {
+		header = http.Header{"Content-Type": {m.patchType}}
+	}
+	return b.request(m.method, url, m.body, header)
+}
+
+func deleteMutation(ns string, resource resourceType, resourceItem string, noGracePeriod bool) mutationType {
+	m := mutationType{method: http.MethodDelete, resource: resource, namespace: ns, name: resourceItem}
 	if noGracePeriod {
-		queryParam = "?gracePeriodSeconds=0"
+		m.query = "gracePeriodSeconds=0"
 	}
+	return m
+}
+
+func scaleMutation(ns string, resource resourceType, deploymentName string, spec interface{}, scale int) mutationType {
+	replicas, _ := strconv.Atoi(val1(spec, "{{.spec.replicas}}"))
+	newReplicas := replicas + scale
+	body := fmt.Sprintf(`{"spec":{ "replicas": %v }}`, newReplicas)
+	return mutationType{method: http.MethodPatch, resource: resource, namespace: ns, name: deploymentName, body: body, patchType: strategicMergePatchType}
+}
+
+// restartMutation changes an annotation of the pod template, so the pods of the workload are replaced, like 'kubectl rollout restart'
+func restartMutation(ns string, resource resourceType, name string) mutationType {
+	body := fmt.Sprintf(`{"spec":{"template":{"metadata":{"annotations":{"kubectl.kubernetes.io/restartedAt":"%s"}}}}}`, time.Now().UTC().Format(time.RFC3339))
+	return mutationType{method: http.MethodPatch, resource: resource, namespace: ns, name: name, body: body, patchType: strategicMergePatchType}
+}
+
+// labelMutation sets the labels of a resource item, labels with a nil value are removed
+func labelMutation(ns string, resource resourceType, name string, labels map[string]interface{}) mutationType {
+	body, _ := json.Marshal(map[string]interface{}{"metadata": map[string]interface{}{"labels": labels}})
+	return mutationType{method: http.MethodPatch, resource: resource, namespace: ns, name: name, body: string(body), patchType: mergePatchType}
+}
+
+// get fetches the current state of a resource item
+func (b *backendType) get(ns string, resource resourceType, name string) (map[string]interface{}, error) {
+	var rc string
+	var err error
 	if resource.Namespace {
-		rc, err = b.restCall(http.MethodDelete, resource.APIPrefix, fmt.Sprintf("%s/%s%s", resource.Name, resourceItem, queryParam), ns, "")
+		rc, err = b.restCall(http.MethodGet, resource.APIPrefix, fmt.Sprintf("%s/%s", resource.Name, name), ns, "")
 	} else {
-		rc, err = b.restCallNoNs(http.MethodDelete, resource.APIPrefix, fmt.Sprintf("%s/%s%s", resource.Name, resourceItem, queryParam), "")
+		rc, err = b.restCallNoNs(http.MethodGet, resource.APIPrefix, fmt.Sprintf("%s/%s", resource.Name, name), "")
 	}
 	if err != nil {
-		return rc, err
+		return nil, err
+	}
+	obj := unmarshall(rc)
+	if obj["kind"] == "Status" {
+		return nil, fmt.Errorf("can't get %s '%s': %v", resource.Name, name, obj["message"])
 	}
-	return unmarshall(rc), nil
+	return obj, nil
 }
 
-func (b *backendType) scale(ns string, resource resourceType, deploymentName string, spec interface{}, scale int) (interface{}, error) {
-	replicas, _ := strconv.Atoi(val1(spec, "{{.spec.replicas}}"))
-	newReplicas := replicas + scale
-	body := fmt.Sprintf(`{"spec":{ "replicas": %v }}`, newReplicas)
-	r, err := b.restCall(http.MethodPatch, resource.APIPrefix, fmt.Sprintf("%s/%s", resource.Name, deploymentName), ns, body)
+// replaceMutation puts the manifest of a resource item, the api server rejects it when its resourceVersion isn't the current one
+func replaceMutation(ns string, resource resourceType, name string, body string) mutationType {
+	return mutationType{method: http.MethodPut, resource: resource, namespace: ns, name: name, body: body}
+}
+
+// createMutation posts a new resource item
+func createMutation(ns string, resource resourceType, body string) mutationType {
+	return mutationType{method: http.MethodPost, resource: resource, namespace: ns, body: body}
+}
+
+// applyMutation configures an existing resource item with server side apply, fields owned by other managers are not overwritten
+func applyMutation(ns string, resource resourceType, name string, body string) mutationType {
+	return mutationType{method: http.MethodPatch, resource: resource, namespace: ns, name: name, query: applyQuery, body: body, patchType: applyPatchType}
+}
+
+// mutateObject sends the mutation and returns the resulting object, a status returned instead is an error
+func (b *backendType) mutateObject(m mutationType, dryRun bool) (map[string]interface{}, error) {
+	rc, err := b.mutate(m, dryRun)
 	if err != nil {
-		return r, err
+		return nil, err
 	}
-	return r, nil
+	obj := unmarshall(rc)
+	if obj["kind"] == "Status" && obj["status"] != "Success" {
+		return nil, fmt.Errorf("can't %s %s '%s': %v", strings.ToLower(m.method), m.resource.Name, m.name, obj["message"])
+	}
+	return obj, nil
 }
 
 func (b *backendType) handleResponse(httpMethod, url, reqBody string, resp *http.Response, err error) (string, error) {
@@ -337,7 +720,7 @@ func (b *backendType) handleResponse(httpMethod, url, reqBody string, resp *http
 	}
 	resp.Body.Close()
 	switch resp.StatusCode {
-	case http.StatusOK:
+	case http.StatusOK, http.StatusCreated:
 		tracelog.Printf("resources found for url: %s", url)
 		return string(respBody), err
 	case http.StatusNotFound:
@@ -351,51 +734,57 @@ func (b *backendType) handleResponse(httpMethod, url, reqBody string, resp *http
 }
 
 func (b *backendType) availabiltyCheck() error {
-	url := fmt.Sprintf("%s/%s/%s", b.context.Cluster.URL, "api/v1", "namespaces")
-	resp, err := b.restExecutor(http.MethodGet, url, "", restCallTimeout)
+	url := fmt.Sprintf("%s/%s", b.context.Cluster.URL, "api")
+	resp, err := b.restExecutor(http.MethodGet, url, "", restCallTimeout, nil)
 	_, err = b.handleResponse(http.MethodGet, url, "", resp, err)
 	return err
 }
 
 func (b *backendType) restCallAll(apiPrefix, ress string, body string) (string, error) {
 	url := fmt.Sprintf("%s/%s/%s", b.context.Cluster.URL, apiPrefix, ress)
-	resp, err := b.restExecutor(http.MethodGet, url, body, restCallTimeout)
+	resp, err := b.restExecutor(http.MethodGet, url, body, restCallTimeout, nil)
 	return b.handleResponse(http.MethodGet, url, body, resp, err)
 }
 
 func (b *backendType) restCall(httpMethod, apiPrefix, ress, ns string, body string) (string, error) {
 	url := fmt.Sprintf("%s/%s/namespaces/%s/%s", b.context.Cluster.URL, apiPrefix, ns, ress)
-	resp, err := b.restExecutor(httpMethod, url, body, restCallTimeout)
-	return b.handleResponse(httpMethod, url, body, resp, err)
+	return b.request(httpMethod, url, body, nil)
 }
 
 func (b *backendType) restCallNoNs(httpMethod, apiPrefix, ress string, body string) (string, error) {
 	url := fmt.Sprintf("%s/%s/%s", b.context.Cluster.URL, apiPrefix, ress)
-	resp, err := b.restExecutor(httpMethod, url, body, restCallTimeout)
+	return b.request(httpMethod, url, body, nil)
+}
+
+// request sends the body with the header fields, which the rest executor doesn't set by itself
+func (b *backendType) request(httpMethod, url, body string, header http.Header) (string, error) {
+	resp, err := b.restExecutor(httpMethod, url, body, restCallTimeout, header)
 	return b.handleResponse(httpMethod, url, body, resp, err)
 }
 
 func (b *backendType) restCallBatch(httpMethod, ress string, ns string, body string) (string, error) {
 	url := fmt.Sprintf("%s/apis/batch/v1/namespaces/%s/%s", b.context.Cluster.URL, ns, ress)
-	resp, err := b.restExecutor(httpMethod, url, body, restCallTimeout)
+	resp, err := b.restExecutor(httpMethod, url, body, restCallTimeout, nil)
 	return b.handleResponse(httpMethod, url, body, resp, err)
 }
 
-// GET /api/v1/watch/pods
-func (b *backendType) watch(apiPrefix string, resName string) error {
-	urlPrefix := fmt.Sprintf("%s/%s/watch", b.context.Cluster.URL, apiPrefix)
-	return b.watch0(urlPrefix, resName, "")
-}
-
 // GET /api/v1/namespaces/{namespace}/pods/{name}/log
 func (b *backendType) watchPodLogs(ns, podName, containerName string) error {
+	b.mutex.Lock()
 	b.podLogs = []byte{}
+	b.mutex.Unlock()
 	urlPrefix := fmt.Sprintf("%s/%s/namespaces/%s", b.context.Cluster.URL, "api/v1", ns)
 	urlPostfix := fmt.Sprintf("pods/%s/log", podName)
 	queryParam := fmt.Sprintf("?tailLines=%v&follow=true&container=%s", 1000, containerName)
 	return b.watch0(urlPrefix, urlPostfix, queryParam)
 }
 
+func (b *backendType) podLog() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return string(b.podLogs)
+}
+
 func (b *backendType) closePodLogsWatch() {
 	b.closeWatches0(func(s string) bool {
 		return strings.HasSuffix(s, "log")
@@ -403,9 +792,9 @@ func (b *backendType) closePodLogsWatch() {
 }
 
 func (b *backendType) watch0(urlPrefix, urlPostfix, queryParam string) error {
-	tracelog.Printf("watching resource : %s", urlPostfix)
+	tracelog.Printf("watching : %s", urlPostfix)
 	url := fmt.Sprintf("%s/%s%s", urlPrefix, urlPostfix, queryParam)
-	resp, err := b.restExecutor(http.MethodGet, url, "", -1)
+	resp, err := b.restExecutor(http.MethodGet, url, "", -1, nil)
 	if err != nil {
 		errorlog.Printf("error watching resource %s : %v", urlPostfix, err)
 		return err
@@ -418,9 +807,11 @@ func (b *backendType) watch0(urlPrefix, urlPostfix, queryParam string) error {
 		errorlog.Printf(mess)
 		return fmt.Errorf(mess)
 	}
-	body := &resp.Body
+	w := newWatch()
+	w.setReader(resp.Body)
+	b.setWatch(urlPostfix, w)
 	go func() {
-		reader := bufio.NewReader(*body)
+		reader := bufio.NewReader(resp.Body)
 		for {
 			watchBytes, err := reader.ReadBytes('\n')
 			if err != nil {
@@ -432,108 +823,99 @@ func (b *backendType) watch0(urlPrefix, urlPostfix, queryParam string) error {
 					errorlog.Print(mess)
 					showError(mess, err)
 				}
-				b.watches[urlPostfix].online = false
+				w.setOnline(false)
 				break
 			} else {
-				b.watches[urlPostfix].online = true
-				if !strings.HasSuffix(urlPostfix, "log") {
-					b.updateResourceItems(urlPostfix, watchBytes)
-				} else {
-					b.podLogs = append(b.podLogs, watchBytes[:]...)
-					updateResourceItemDetailPart()
-				}
+				w.setOnline(true)
+				b.mutex.Lock()
+				b.podLogs = append(b.podLogs, watchBytes[:]...)
+				b.mutex.Unlock()
+				updateResourceItemDetailPart()
 			}
 		}
 	}()
-
-	b.watches[urlPostfix] = &watchType{reader: body, online: false}
 	return nil
 }
 
-func (b *backendType) indexOfResItemByName(resName, name string) int {
-	items := b.resItems[resName]
-	for i, item := range items {
-		if resItemName(item) == name {
-			return i
-		}
-	}
-	return -1
-}
-
-func (b *backendType) updateResourceItems(resName string, watchBytes []byte) {
-	watch := unmarshallBytes(watchBytes)
+func (b *backendType) updateResourceItems(resKey string, watch map[string]interface{}) {
 	if watch["object"] != nil {
 		watchObj := watch["object"].(map[string]interface{})
 		switch watch["type"] {
 		case "MODIFIED":
-			b.updateResourceItem(resName, watchObj)
+			b.updateResourceItem(resKey, watchObj)
 		case "ADDED":
-			b.addResourceItem(resName, watchObj)
+			b.addResourceItem(resKey, watchObj)
 		case "DELETED":
-			b.deleteResourceItem(resName, watchObj)
+			b.deleteResourceItem(resKey, watchObj)
+		case "BOOKMARK":
+			// only carries the resourceVersion to resume the watch from
+			return
 		default:
-			errorlog.Printf("unknown watch type , resource: %s, watch: %s", resName, watch)
+			errorlog.Printf("unknown watch type , resource: %s, watch: %s", resKey, watch)
 		}
-		if resName == "namespaces" {
+		b.markChanged(fmt.Sprintf("%s/%s/%s", resItemNamespace(watchObj), resourceNameOfKey(resKey), resItemName(watchObj)))
+
+		if resKey == namespacesKey {
 			updateNamespaces()
 		}
-		chngObj := changedObjType{id: fmt.Sprintf("%s/%s/%s", resItemNamespace(watchObj), resName, resItemName(watchObj)), changeTime: time.Now()}
-
-		b.changedObjList = append(b.changedObjList, chngObj)
-		b.changedObjSet[chngObj.id] = true
-
-		if len(resourceMenu.widget.items) > 0 && len(namespaceList.widget.items) > 0 {
-			selRes := selectedResource()
-			selNs := selectedNamespace()
-
-			if currentState.name == "browseState" && selRes.Name == resName && (selNs == "*ALL*" || selNs == resItemNamespace(watchObj)) && (watch["type"] == "DELETED" || watch["type"] == "ADDED") {
-				updateResourceItemList(true)
-			}
+		if resKey == crdKey {
+			updateCustomResources()
+		}
+		if watch["type"] == "DELETED" || watch["type"] == "ADDED" {
+			b.resourceItemsChanged(resKey, resItemNamespace(watchObj))
 		}
 	} else {
 		errorlog.Printf("unknown watch obj: %v", watch)
 	}
+}
+
+// resourceItemsChanged updates the ui when items of the resource in namespace ns were added or removed
+func (b *backendType) resourceItemsChanged(resKey, ns string) {
+	if g == nil {
+		return
+	}
+	if len(resourceMenu.widget.items) > 0 && len(namespaceList.widget.items) > 0 {
+		selRes := selectedResource()
+		selNs := selectedNamespace()
 
-	//tracelog.Printf("count of %s:  : %d ", resName, len(b.resItems[resName]))
+		if currentState.name == "browseState" && selRes.key() == resKey && (selNs == "*ALL*" || ns == "*ALL*" || selNs == ns) {
+			updateResourceItemList(true)
+		}
+	}
 }
 
-func (b *backendType) updateResourceItem(resName string, ri map[string]interface{}) {
-	name := resItemName(ri)
-	// tracelog.Printf("update ri: (%s, %s)", resName, name)
-	i := b.indexOfResItemByName(resName, name)
-	if i > -1 {
-		items := b.resItems[resName]
-		items[i] = ri
-	} else {
-		warninglog.Printf("update: ri  (%s/%s) not found", resName, name)
+// updateResourceItem replaces the item, an unknown item is added
+func (b *backendType) updateResourceItem(resKey string, ri map[string]interface{}) {
+	if b.store.upsert(resKey, ri) {
+		tracelog.Printf("update: ri (%s/%s) not found, added it", resKey, resItemName(ri))
 	}
 }
 
-func (b *backendType) addResourceItem(resName string, ri map[string]interface{}) {
-	// name := resItemName(ri)
-	// tracelog.Printf("add ri: (%s, %s)", resName, name)
-	items := b.resItems[resName]
-	b.resItems[resName] = append(items, ri)
+// addResourceItem adds the item, a known item is replaced. Events may be delivered again after a watch was resumed
+func (b *backendType) addResourceItem(resKey string, ri map[string]interface{}) {
+	if !b.store.upsert(resKey, ri) {
+		tracelog.Printf("add: ri (%s/%s) already known, replaced it", resKey, resItemName(ri))
+	}
 }
 
-func (b *backendType) deleteResourceItem(resName string, ri map[string]interface{}) {
+func (b *backendType) deleteResourceItem(resKey string, ri map[string]interface{}) {
 	name := resItemName(ri)
-	tracelog.Printf("delete ri (%s/%s)", resName, name)
-	i := b.indexOfResItemByName(resName, name)
-	if i > -1 {
-		items := b.resItems[resName]
-		b.resItems[resName] = append(items[:i], items[i+1:]...)
-	} else {
-		warninglog.Printf("delete: ri (%s/%s) not found ", resName, name)
+	tracelog.Printf("delete ri (%s/%s)", resKey, name)
+	if !b.store.delete(resKey, ri) {
+		warninglog.Printf("delete: ri (%s/%s) not found ", resKey, name)
 	}
 }
 
-func kubectl(ctx string, a1 string, a ...string) *exec.Cmd {
-	context := fmt.Sprintf("--context=%s", ctx)
+// kubectl command of the verb in the namespace for the context of the backend, if the policy of the context allows it
+func (b *backendType) kubectl(verb, ns string, a ...string) (*exec.Cmd, error) {
+	if err := b.checkPolicy("kubectl " + verb); err != nil {
+		return nil, err
+	}
+	context := fmt.Sprintf("--context=%s", b.context.Name)
 	timeout := fmt.Sprintf("--request-timeout=%ds", kubeCtlTimeout)
-	full := append([]string{context, timeout, a1}, a[:]...)
+	full := append([]string{context, timeout, "-n", ns, verb}, a[:]...)
 	tracelog.Printf("kubectl %v", full)
-	return execCommand("kubectl", full...)
+	return execCommand("kubectl", full...), nil
 }
 
 func runCmd(cmd *exec.Cmd) (string, string, error) {
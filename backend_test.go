@@ -0,0 +1,119 @@
+package kubexp
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var testAPIHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"kind":"NamespaceList","items":[]}`))
+})
+
+func newTestAPIServer() *httptest.Server {
+	return httptest.NewTLSServer(testAPIHandler)
+}
+
+func testContext(server *httptest.Server, cluster clusterType) contextType {
+	u, _ := url.Parse(server.URL)
+	cluster.Name = "test"
+	cluster.URL = u
+	return contextType{Name: "test", Cluster: cluster}
+}
+
+func caDataOf(server *httptest.Server) string {
+	caPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	return base64.StdEncoding.EncodeToString(caPem)
+}
+
+func Test_TLSVerifiesWithCertificateAuthorityData(t *testing.T) {
+	require := require.New(t)
+	server := newTestAPIServer()
+	defer server.Close()
+
+	be := newBackend(testContext(server, clusterType{certificateAuthorityData: caDataOf(server)}))
+	require.Nil(be.availabiltyCheck())
+}
+
+func Test_TLSRejectsUnknownAuthority(t *testing.T) {
+	require := require.New(t)
+	server := newTestAPIServer()
+	defer server.Close()
+
+	be := newBackend(testContext(server, clusterType{}))
+	err := be.availabiltyCheck()
+	require.NotNil(err)
+	require.Contains(err.Error(), "certificate")
+}
+
+func Test_TLSInsecureSkipVerify(t *testing.T) {
+	require := require.New(t)
+	server := newTestAPIServer()
+	defer server.Close()
+
+	be := newBackend(testContext(server, clusterType{insecureSkipTLSVerify: true}))
+	require.Nil(be.availabiltyCheck())
+}
+
+func Test_TLSInvalidCertificateAuthorityData(t *testing.T) {
+	require := require.New(t)
+	_, err := newTLSConfig(contextType{Cluster: clusterType{Name: "broken", certificateAuthorityData: "blabla"}})
+	require.NotNil(err)
+}
+
+func newClientCertificate(t *testing.T) (*x509.Certificate, string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "kubernetes-admin", Organization: []string{"system:masters"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.Nil(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.Nil(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.Nil(t, err)
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	return cert, base64.StdEncoding.EncodeToString(certPem), base64.StdEncoding.EncodeToString(keyPem)
+}
+
+func Test_TLSClientCertificate(t *testing.T) {
+	require := require.New(t)
+	clientCert, certData, keyData := newClientCertificate(t)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+
+	server := httptest.NewUnstartedServer(testAPIHandler)
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	defer server.Close()
+
+	ctx := testContext(server, clusterType{certificateAuthorityData: caDataOf(server)})
+	require.NotNil(newBackend(ctx).availabiltyCheck(), "server must reject requests without client certificate")
+
+	ctx.user = userType{Name: "kubernetes-admin", clientCertificateData: certData, clientKeyData: keyData}
+	require.True(ctx.user.hasClientCertificate())
+	require.Nil(newBackend(ctx).availabiltyCheck())
+}
@@ -0,0 +1,174 @@
+package kubexp
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// markedItems keys of the marked items of markedScope, the bulk actions run against them instead of the selected item
+var markedItems = map[string]bool{}
+
+// markedScope context, namespace and resource of the marked items
+var markedScope = ""
+
+// maximum of items listed in a confirm dialog
+var bulkConfirmMaxItems = 20
+
+var labelKeyRegexp = regexp.MustCompile(`^([a-z0-9A-Z.-]+/)?[a-z0-9A-Z]([a-z0-9A-Z_.-]*[a-z0-9A-Z])?$`)
+var labelValueRegexp = regexp.MustCompile(`^([a-z0-9A-Z]([a-z0-9A-Z_.-]*[a-z0-9A-Z])?)?$`)
+
+// bulkTargetType resource item a bulk action runs against
+type bulkTargetType struct {
+	namespace, name string
+	item            interface{}
+}
+
+// bulkResultType outcome of a bulk action on one resource item, err is nil if it succeeded
+type bulkResultType struct {
+	target bulkTargetType
+	err    error
+}
+
+// markKey the uid of the item, items recreated with the same name aren't marked. Items without uid are identified by namespace and name
+func markKey(ri interface{}) string {
+	if uid := resItemUID(ri); uid != "" {
+		return uid
+	}
+	return resItemNamespace(ri) + "/" + resItemName(ri)
+}
+
+func isMarked(ri interface{}) bool {
+	return markedItems[markKey(ri)]
+}
+
+// resetMarks removes the marks, when the items of another context, namespace or resource are shown
+func resetMarks(contextName, ns, resKey string) {
+	scope := contextName + "/" + ns + "/" + resKey
+	if scope != markedScope {
+		markedItems = map[string]bool{}
+		markedScope = scope
+	}
+}
+
+func clearMarks() {
+	markedItems = map[string]bool{}
+}
+
+func toggleMark(ri interface{}) {
+	if isMarked(ri) {
+		delete(markedItems, markKey(ri))
+	} else {
+		markedItems[markKey(ri)] = true
+	}
+}
+
+// toggleMarks marks all items, all are unmarked if they are already marked
+func toggleMarks(items []interface{}) {
+	all := true
+	for _, ri := range items {
+		all = all && isMarked(ri)
+	}
+	for _, ri := range items {
+		if all {
+			delete(markedItems, markKey(ri))
+		} else {
+			markedItems[markKey(ri)] = true
+		}
+	}
+}
+
+// bulkTargets the marked items, the selected item if there are none
+func bulkTargets(items []interface{}, selected interface{}) []bulkTargetType {
+	targets := []bulkTargetType{}
+	for _, ri := range items {
+		if isMarked(ri) {
+			targets = append(targets, bulkTargetType{namespace: resItemNamespace(ri), name: resItemName(ri), item: ri})
+		}
+	}
+	if len(targets) == 0 && selected != nil {
+		targets = append(targets, bulkTargetType{namespace: resItemNamespace(selected), name: resItemName(selected), item: selected})
+	}
+	return targets
+}
+
+func (t bulkTargetType) String() string {
+	if t.namespace == "" {
+		return t.name
+	}
+	return t.namespace + "/" + t.name
+}
+
+// runBulk runs the action on the targets one after another, a failure doesn't stop the others
+func runBulk(targets []bulkTargetType, action func(t bulkTargetType) error) []bulkResultType {
+	results := make([]bulkResultType, len(targets))
+	for i, t := range targets {
+		results[i] = bulkResultType{target: t, err: action(t)}
+		if results[i].err != nil {
+			errorlog.Printf("bulk action on %s failed: %v", t, results[i].err)
+		}
+	}
+	return results
+}
+
+// bulkConfirmMessage lists all items affected by the action
+func bulkConfirmMessage(action, resName string, targets []bulkTargetType) string {
+	if len(targets) == 1 {
+		return fmt.Sprintf("%s %s '%s' ?", action, resName, targets[0])
+	}
+	lines := []string{fmt.Sprintf("%s %d %s ?", action, len(targets), resName)}
+	for i, t := range targets {
+		if i == bulkConfirmMaxItems {
+			lines = append(lines, fmt.Sprintf("  ... and %d more", len(targets)-i))
+			break
+		}
+		lines = append(lines, "  "+t.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// bulkSummary counts of the succeeded and failed actions, followed by the result of each item, failures first
+func bulkSummary(action, resName string, results []bulkResultType) string {
+	failed, succeeded := []string{}, []string{}
+	for _, r := range results {
+		if r.err != nil {
+			mess := strings.SplitN(strings.TrimSpace(r.err.Error()), "\n", 2)[0]
+			failed = append(failed, colorizeText("  failed ", 0, 9, redEmpInlineColor)+fmt.Sprintf("%s: %s", r.target, mess))
+		} else {
+			succeeded = append(succeeded, colorizeText("  ok     ", 0, 9, greenEmpInlineColor)+r.target.String())
+		}
+	}
+	lines := []string{fmt.Sprintf("%s %s: %d succeeded, %d failed", action, resName, len(succeeded), len(failed))}
+	return strings.Join(append(append(lines, failed...), succeeded...), "\n")
+}
+
+// parseLabelChanges parses comma separated 'key=value' to set and 'key-' to remove labels, removed labels have a nil value
+func parseLabelChanges(text string) (map[string]interface{}, error) {
+	changes := map[string]interface{}{}
+	for _, term := range strings.Split(text, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		if strings.HasSuffix(term, "-") && !strings.Contains(term, "=") {
+			key := strings.TrimSuffix(term, "-")
+			if !labelKeyRegexp.MatchString(key) {
+				return nil, fmt.Errorf("invalid label key '%s'", key)
+			}
+			changes[key] = nil
+			continue
+		}
+		kv := strings.SplitN(term, "=", 2)
+		if len(kv) != 2 || !labelKeyRegexp.MatchString(kv[0]) {
+			return nil, fmt.Errorf("invalid label '%s', expected 'key=value' or 'key-'", term)
+		}
+		if !labelValueRegexp.MatchString(kv[1]) {
+			return nil, fmt.Errorf("invalid value of label '%s'", term)
+		}
+		changes[kv[0]] = kv[1]
+	}
+	if len(changes) == 0 {
+		return nil, fmt.Errorf("no labels given")
+	}
+	return changes, nil
+}
@@ -0,0 +1,124 @@
+package kubexp
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_BulkTargets(t *testing.T) {
+	require := require.New(t)
+	resetMarks("test", "*ALL*", "pods")
+	defer resetMarks("", "", "")
+	items := []interface{}{testItem("dev", "a"), testItem("dev", "b"), testItem("prod", "c")}
+
+	targets := bulkTargets(items, items[1])
+	require.Len(targets, 1, "selected item without marks")
+	require.Equal("dev/b", targets[0].String())
+
+	toggleMark(items[0])
+	toggleMark(items[2])
+	targets = bulkTargets(items, items[1])
+	require.Equal([]string{"dev/a", "prod/c"}, []string{targets[0].String(), targets[1].String()})
+	toggleMark(items[2])
+	require.Len(bulkTargets(items, items[1]), 1)
+
+	toggleMarks(items[:2])
+	require.Len(bulkTargets(items, nil), 2, "all are marked")
+	toggleMarks(items[:2])
+	require.Empty(bulkTargets(items, nil), "all are unmarked again")
+
+	toggleMark(items[0])
+	resetMarks("test", "*ALL*", "pods")
+	require.True(isMarked(items[0]), "marks are kept for the same resource")
+	resetMarks("test", "*ALL*", "deployments")
+	require.False(isMarked(items[0]))
+
+	for _, scope := range [][]string{{"test", "dev", "pods"}, {"prod", "*ALL*", "pods"}} {
+		resetMarks("test", "*ALL*", "pods")
+		toggleMark(items[0])
+		resetMarks(scope[0], scope[1], scope[2])
+		require.False(isMarked(items[0]), "marks are removed in another namespace or context")
+		require.Empty(markedItems)
+	}
+}
+
+func Test_MarksAreKeyedByUID(t *testing.T) {
+	require := require.New(t)
+	resetMarks("test", "*ALL*", "pods")
+	defer resetMarks("", "", "")
+	marked := testItemWithUID("dev", "web", "u1")
+	toggleMark(marked)
+	require.True(isMarked(marked))
+	require.False(isMarked(testItemWithUID("dev", "web", "u2")), "an item with the same namespace and name isn't marked")
+	targets := bulkTargets([]interface{}{testItemWithUID("dev", "web", "u2"), marked}, nil)
+	require.Len(targets, 1)
+	require.Equal(marked, targets[0].item)
+}
+
+func Test_BulkMessages(t *testing.T) {
+	require := require.New(t)
+	targets := []bulkTargetType{{namespace: "dev", name: "a"}, {namespace: "dev", name: "b"}, {name: "c"}}
+	require.Equal("Delete pods 'dev/a' ?", bulkConfirmMessage("Delete", "pods", targets[:1]))
+	require.Equal("Delete 3 pods ?\n  dev/a\n  dev/b\n  c", bulkConfirmMessage("Delete", "pods", targets))
+	defer func(max int) { bulkConfirmMaxItems = max }(bulkConfirmMaxItems)
+	bulkConfirmMaxItems = 2
+	require.Equal("Delete 3 pods ?\n  dev/a\n  dev/b\n  ... and 1 more", bulkConfirmMessage("Delete", "pods", targets))
+
+	results := runBulk(targets, func(t bulkTargetType) error {
+		if t.name == "b" {
+			return errors.New("HTTP-Status: 404\nnot found")
+		}
+		return nil
+	})
+	summary := bulkSummary("Delete", "pods", results)
+	lines := strings.Split(inlineColorRegexp.ReplaceAllString(summary, ""), "\n")
+	require.Equal([]string{"Delete pods: 2 succeeded, 1 failed", "  failed dev/b: HTTP-Status: 404", "  ok     dev/a", "  ok     c"}, lines)
+}
+
+func Test_ParseLabelChanges(t *testing.T) {
+	require := require.New(t)
+	changes, err := parseLabelChanges("app=web, tier-,app.kubernetes.io/part-of=shop,empty=")
+	require.Nil(err)
+	require.Equal(map[string]interface{}{"app": "web", "tier": nil, "app.kubernetes.io/part-of": "shop", "empty": ""}, changes)
+	for _, invalid := range []string{"", "app", "=web", "app=w b", "a b=c", "app=-web"} {
+		_, err = parseLabelChanges(invalid)
+		require.NotNil(err, invalid)
+	}
+}
+
+func Test_BulkRequests(t *testing.T) {
+	require := require.New(t)
+	var mutex sync.Mutex
+	requests := []string{}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		mutex.Lock()
+		requests = append(requests, fmt.Sprintf("%s %s %s %s", r.Method, r.URL.Path, r.Header.Get("Content-Type"), body))
+		mutex.Unlock()
+		fmt.Fprint(w, `{}`)
+	}))
+	defer server.Close()
+	be := newTestWatchBackend(server)
+	deployments := resourceType{Name: "deployments", APIPrefix: "apis/apps/v1", Namespace: true}
+	nodes := resourceType{Name: "nodes", APIPrefix: "api/v1"}
+
+	_, err := be.mutate(restartMutation("dev", deployments, "web"), false)
+	require.Nil(err)
+	_, err = be.mutate(labelMutation("dev", deployments, "web", map[string]interface{}{"app": "web", "tier": nil}), false)
+	require.Nil(err)
+	_, err = be.mutate(labelMutation("", nodes, "node1", map[string]interface{}{"pool": "a"}), false)
+	require.Nil(err)
+
+	require.Len(requests, 3)
+	require.Regexp(`^PATCH /apis/apps/v1/namespaces/dev/deployments/web application/strategic-merge-patch\+json \{"spec":\{"template":\{"metadata":\{"annotations":\{"kubectl.kubernetes.io/restartedAt":"\d{4}-.*Z"\}\}\}\}\}$`, requests[0])
+	require.Equal(`PATCH /apis/apps/v1/namespaces/dev/deployments/web application/merge-patch+json {"metadata":{"labels":{"app":"web","tier":null}}}`, requests[1])
+	require.Equal(`PATCH /api/v1/nodes/node1 application/merge-patch+json {"metadata":{"labels":{"pool":"a"}}}`, requests[2])
+}
@@ -48,8 +48,11 @@ func newExecCommand(name, cmd string, containerNr int) commandType {
 			containerNr = 0
 		}
 
-		cmd := kubectl(backend.context.Name, "-n", ns, "exec", "-c", containerNames[containerNr], "-it", rname, cmd)
-
+		cmd, err := backend.kubectl("exec", ns, "-c", containerNames[containerNr], "-it", rname, cmd)
+		if err != nil {
+			showError(fmt.Sprintf("Can't exec in pod '%s'", rname), err)
+			return nil
+		}
 		exe <- cmd
 		return gocui.ErrQuit
 	}}
@@ -115,14 +118,6 @@ var previousResourceCommand = commandType{Name: "Previous resource", f: func(g *
 	return nil
 }}
 
-func newConfirmCommand(name string, command commandType) commandType {
-	var confirmCommand = commandType{Name: name, f: func(g *gocui.Gui, v *gocui.View) error {
-		showConfirm(fmt.Sprintf("Delete %s from %s ?", selectedResourceItemName(), selectedResource().Name), command)
-		return nil
-	}}
-	return confirmCommand
-}
-
 func newLoadingCommand(name string, command commandType) commandType {
 	var loadingCommand = commandType{Name: name, f: func(g *gocui.Gui, v *gocui.View) error {
 		showLoading(command, g, v)
@@ -137,18 +132,80 @@ var executeConfirmCommand = commandType{Name: "Execute command to confirm", f: f
 	return nil
 }}
 
-var deleteCommand = commandType{Name: "Delete resource", f: func(g *gocui.Gui, v *gocui.View) error {
-	deleteResource(false)
+var deleteConfirmCommand = commandType{Name: "Delete resource", f: func(g *gocui.Gui, v *gocui.View) error {
+	deleteResources(false)
 	return nil
 }}
 
-var deleteNoGracePeriodCommand = commandType{Name: "Delete resource", f: func(g *gocui.Gui, v *gocui.View) error {
-	deleteResource(true)
+var deleteNoGracePeriodConfirmCommand = commandType{Name: "Delete resource immediately", f: func(g *gocui.Gui, v *gocui.View) error {
+	deleteResources(true)
 	return nil
 }}
 
-var deleteConfirmCommand = newConfirmCommand("Delete resource", deleteCommand)
-var deleteNoGracePeriodConfirmCommand = newConfirmCommand("Delete resource immediately", deleteNoGracePeriodCommand)
+var restartConfirmCommand = commandType{Name: "Restart resource", f: func(g *gocui.Gui, v *gocui.View) error {
+	restartResources()
+	return nil
+}}
+
+var toggleMarkCommand = commandType{Name: "Mark resource item", f: func(g *gocui.Gui, v *gocui.View) error {
+	if len(resourceItemsList.widget.items) == 0 {
+		return nil
+	}
+	toggleMark(resourceItemsList.widget.items[resourceItemsList.widget.selectedItem])
+	resourceItemsList.widget.nextSelectedItem()
+	updateResourceItemsListTitle(selectedResource())
+	return nil
+}}
+
+var toggleMarksCommand = commandType{Name: "Mark all resource items", f: func(g *gocui.Gui, v *gocui.View) error {
+	toggleMarks(resourceItemsList.widget.items)
+	updateResourceItemsListTitle(selectedResource())
+	return nil
+}}
+
+var gotoLabelStateCommand = commandType{Name: "Label resource items", f: func(g *gocui.Gui, v *gocui.View) error {
+	if len(resourceItemsList.widget.items) > 0 {
+		setState(labelState)
+	}
+	return nil
+}}
+
+var setLabelsCommand = commandType{Name: "Set labels", f: func(g *gocui.Gui, v *gocui.View) error {
+	setLabels(v.Buffer())
+	return nil
+}}
+
+var editCommand = commandType{Name: "Edit in $EDITOR", f: func(g *gocui.Gui, v *gocui.View) error {
+	if len(resourceItemsList.widget.items) == 0 {
+		return nil
+	}
+	discardEdit()
+	res := selectedResource()
+	ns := selectedResourceItemNamespace()
+	rname := selectedResourceItemName()
+	e, err := newEdit(backend, res, ns, rname)
+	if err != nil {
+		showError(fmt.Sprintf("Can't edit %s on namespace %s with name '%s' ", res.Name, ns, rname), err)
+		return nil
+	}
+	pendingEdit = e
+	exe <- e.editorCmd()
+	return gocui.ErrQuit
+}}
+
+var editAgainCommand = commandType{Name: "Edit rejected manifest again", f: func(g *gocui.Gui, v *gocui.View) error {
+	if pendingEdit == nil || pendingEdit.err == nil {
+		return nil
+	}
+	exe <- pendingEdit.editorCmd()
+	return gocui.ErrQuit
+}}
+
+var discardEditCommand = commandType{Name: "quit", f: func(g *gocui.Gui, v *gocui.View) error {
+	discardEdit()
+	setState(browseState)
+	return nil
+}}
 
 var nameSortCommand = commandType{Name: "Sort by name", f: func(g *gocui.Gui, v *gocui.View) error {
 	nameSorting()
@@ -162,6 +219,18 @@ var ageSortCommand = commandType{Name: "Sort by age", f: func(g *gocui.Gui, v *g
 	return nil
 }}
 
+var columnSortCommand = commandType{Name: "Sort by next column", f: func(g *gocui.Gui, v *gocui.View) error {
+	nextSortColumn()
+	newResource()
+	return nil
+}}
+
+var reverseSortCommand = commandType{Name: "Reverse sort order", f: func(g *gocui.Gui, v *gocui.View) error {
+	reverseSortOrder()
+	newResource()
+	return nil
+}}
+
 var scaleUpCommand = newScaleCommand("Scale up", 1)
 var scaleDownCommand = newScaleCommand("Scale down", -1)
 
@@ -271,6 +340,26 @@ var scrollDownHelpCommand = commandType{Name: "Help scroll down", f: func(g *goc
 	return nil
 }}
 
+var scrollUpPreviewCommand = commandType{Name: "Preview scroll up", f: func(g *gocui.Gui, v *gocui.View) error {
+	previewWidget.scrollUp(1)
+	return nil
+}}
+
+var scrollDownPreviewCommand = commandType{Name: "Preview scroll down", f: func(g *gocui.Gui, v *gocui.View) error {
+	previewWidget.scrollDown(1)
+	return nil
+}}
+
+var pageUpPreviewCommand = commandType{Name: "Preview page up", f: func(g *gocui.Gui, v *gocui.View) error {
+	previewWidget.scrollUp(previewWidget.h)
+	return nil
+}}
+
+var pageDownPreviewCommand = commandType{Name: "Preview page down", f: func(g *gocui.Gui, v *gocui.View) error {
+	previewWidget.scrollDown(previewWidget.h)
+	return nil
+}}
+
 var scrollRightCommand = commandType{Name: "TextArea scroll right", f: func(g *gocui.Gui, v *gocui.View) error {
 	resourceItemDetailsWidget.scrollRight()
 	return nil
@@ -290,6 +379,15 @@ var loadContextCommand = newLoadingCommand("reload context", commandType{Name: "
 	return nil
 }})
 
+var checkContextCommand = commandType{Name: "Check context health", f: func(g *gocui.Gui, v *gocui.View) error {
+	ctx := cfg.contexts[clusterList.widget.selectedItem]
+	go func() {
+		ctx.probe()
+		refreshClusterList()
+	}()
+	return nil
+}}
+
 var nextContextCommand = commandType{Name: "Next context", f: func(g *gocui.Gui, v *gocui.View) error {
 	clusterList.widget.nextSelectedItem()
 	return nil
@@ -309,6 +407,27 @@ var previousContextPageCommand = commandType{Name: "Next context", f: func(g *go
 	return nil
 }}
 
+var gotoSelectorStateCommand = commandType{Name: "Select items by labels and fields", f: func(g *gocui.Gui, v *gocui.View) error {
+	setState(selectorState)
+	return nil
+}}
+
+var setSelectorCommand = commandType{Name: "Set selector", f: func(g *gocui.Gui, v *gocui.View) error {
+	setSelector(v.Buffer())
+	return nil
+}}
+
+var gotoQuickFilterStateCommand = commandType{Name: "Filter resource items", f: func(g *gocui.Gui, v *gocui.View) error {
+	setState(quickFilterState)
+	return nil
+}}
+
+var clearQuickFilterCommand = commandType{Name: "Clear filter", f: func(g *gocui.Gui, v *gocui.View) error {
+	setQuickFilter("")
+	setState(browseState)
+	return nil
+}}
+
 var gotoSelectNamespaceStateCommand = commandType{Name: "Select namespace", f: func(g *gocui.Gui, v *gocui.View) error {
 	if namespaceList.widget.visible {
 		setState(selectNsState)
@@ -407,6 +526,10 @@ var gotoFileCommand = commandType{Name: "Transfer file", f: func(g *gocui.Gui, v
 			setFileListContent(fileItem["name"].(string))
 		} else {
 			sourceFile = fileBrowser.getPath(fileItem["name"].(string))
+			if fileBrowser.manifestSelection {
+				createFromManifest(sourceFile)
+				return nil
+			}
 			if fileBrowser.local {
 				fileBrowser = newRemoteFileBrowser(false, "/")
 			} else {
@@ -425,6 +548,13 @@ var gotoFileCommand = commandType{Name: "Transfer file", f: func(g *gocui.Gui, v
 	return nil
 }}
 
+var createFromManifestCommand = commandType{Name: "Create from manifest", f: func(g *gocui.Gui, v *gocui.View) error {
+	setState(manifestFileState)
+	fileBrowser = newManifestFileBrowser(".")
+	setFileListContent("")
+	return nil
+}}
+
 var nextContainerFiletransferCommand = commandType{Name: "Next container", f: func(g *gocui.Gui, v *gocui.View) error {
 	nextFileTransferContainer()
 	return nil
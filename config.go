@@ -1,11 +1,13 @@
 package kubexp
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 
 	yaml "gopkg.in/yaml.v2"
 )
@@ -14,32 +16,85 @@ var kubeConfig string
 
 var configFile *string
 
+var startContext *string
+
+var startNamespace *string
+
+var serviceAccount *string
+
+var watchNamespaces *string
+
 var useResourceFile = false
 
 var contextColors = []string{"Magenta", "Cyan", "Blue"}
 
+// readOnlyColor frame color of read-only contexts
+var readOnlyColor = "Red"
+
 type configType struct {
-	isNew      bool
-	configFile string
-	contexts   []contextType
-	resources  []resourceType
+	isNew          bool
+	configFile     string
+	contexts       []contextType
+	currentContext int
+	resources      []resourceType
+	// configuredResources resources of the resources file or the default ones, before they are resolved with the api discovery
+	configuredResources []resourceType
+	// apiResources configured resources resolved with the api discovery and the other served resources, before the custom resource definitions are applied
+	apiResources []resourceType
+	// customResourceKeys group/name of the resources of all applied custom resource definitions, including deleted ones
+	customResourceKeys map[string]bool
+	// apiKinds served resources by group/kind, e.g. 'batch/Job', the core api has an empty group
+	apiKinds map[string]apiResourceType
 }
 
 type contextType struct {
-	Name    string
-	Cluster clusterType
-	user    userType
-	color   string
+	Name      string
+	Cluster   clusterType
+	user      userType
+	namespace string
+	color     string
+	health    *contextHealthType
+	policy    policyType
+}
+
+// defaultNamespace namespace which is selected when the context is loaded, the -namespace flag overrides the one of the kubeconfig
+func (c contextType) defaultNamespace() string {
+	if startNamespace != nil && *startNamespace != "" {
+		return *startNamespace
+	}
+	return c.namespace
+}
+
+// AuthStrategy name of the authentication strategy in use
+func (c contextType) AuthStrategy() string {
+	return c.user.authStrategyName()
 }
 
 type clusterType struct {
-	Name string
-	URL  *url.URL
+	Name                     string
+	URL                      *url.URL
+	certificateAuthority     string
+	certificateAuthorityData string
+	insecureSkipTLSVerify    bool
+	tlsServerName            string
 }
 
 type userType struct {
-	Name  string
-	token string
+	Name                  string
+	token                 string
+	tokenFile             string
+	clientCertificate     string
+	clientCertificateData string
+	clientKey             string
+	clientKeyData         string
+	exec                  *execCredentialProvider
+	username, password    string
+	serviceAccount        *tokenRequestProvider
+	auth                  *authStrategyType
+}
+
+func (u userType) hasClientCertificate() bool {
+	return (u.clientCertificate != "" || u.clientCertificateData != "") && (u.clientKey != "" || u.clientKeyData != "")
 }
 
 type resourceType struct {
@@ -51,6 +106,20 @@ type resourceType struct {
 	Views           []viewType
 }
 
+// key of the resource in the store and the watches, group/name for resources of api groups and the name for the core api.
+// Resources of different groups may have the same name, e.g. networkpolicies of networking.k8s.io and crd.projectcalico.org
+func (r resourceType) key() string {
+	if group := apiGroupOf(r.APIPrefix); group != "" {
+		return group + "/" + r.Name
+	}
+	return r.Name
+}
+
+// resourceNameOfKey name of the resource with the key, e.g. 'deployments' for 'apps/deployments'
+func resourceNameOfKey(key string) string {
+	return key[strings.LastIndex(key, "/")+1:]
+}
+
 type viewType struct {
 	Name     string
 	Template string
@@ -682,14 +751,14 @@ ports: {{ printArray $e.ports }}  to: {{ printArray $e.to }}
 			yamlView,
 			jsonView,
 		}},
-	{Name: "customresourcedefinitions", APIPrefix: "apis/apiextensions.k8s.io/v1beta1", ShortName: "customres", Category: "cluster/metadata", Namespace: true, Watch: true,
+	{Name: "customresourcedefinitions", APIPrefix: "apis/apiextensions.k8s.io/v1", ShortName: "customres", Category: "cluster/metadata", Namespace: false, Watch: true,
 		Views: []viewType{
 			{
 				Name: "list",
 				Template: nameAgeColumns + `
 {{- header "Group" . .spec.group | printf "%-20.20s " -}}
 {{- header "Kind" . .spec.names.kind | printf "%-20.20s " -}}
-{{- header "Version" . .spec.version | printf "%-20.20s " -}}
+{{- header "Versions" . (jsonPath . ".spec.versions[?(@.served==true)].name") | printf "%-20.20s " -}}
 			`},
 			infoView,
 			yamlView,
@@ -760,7 +829,7 @@ func (c *configType) resView(res resourceType, filter func(viewType) bool) (ret
 }
 
 func (c *configType) allResourceCategories() []string {
-	return []string{"cluster/metadata", "workloads", "config/storage/discovery/loadbalancing", "namespace/metadata"}
+	return []string{"cluster/metadata", "workloads", "config/storage/discovery/loadbalancing", "namespace/metadata", "custom"}
 }
 
 func (c *configType) createResources() *configType {
@@ -795,77 +864,155 @@ func (c *configType) createResources() *configType {
 		if err != nil {
 			errorlog.Fatalf("Didn't understand the yaml in file %s: %v", p, err.Error())
 		}
-		c.resources = r
+		c.configuredResources = r
 	} else {
-		c.resources = defaultResources
+		c.configuredResources = defaultResources
 	}
+	c.resources = c.configuredResources
+	c.apiResources = c.configuredResources
 	return c
 }
 
 func (c *configType) createContexts() *configType {
-	clustersData, err := ioutil.ReadFile(c.configFile)
-	if err != nil {
-		fatalStderrlog.Fatalf("Can't read file %s: %v", c.configFile, err.Error())
+	cfg := c.loadKubeconfig()
+	if cfg["contexts"] == nil {
+		fatalStderrlog.Fatalf("No contexts found in '%s'", c.configFile)
 	}
-	mess := fmt.Sprintf("reading clusters config from %s:", c.configFile)
-	fmt.Println(mess)
-	tracelog.Print(mess)
-
-	var cfg map[string]interface{}
-	err = yaml.Unmarshal(clustersData, &cfg)
-	if err != nil {
-		fatalStderrlog.Fatalf("Didn't understand the yaml in file %s: %v", c.configFile, err.Error())
+	currentContext := configValue(cfg, `{{ index . "current-context" }}`)
+	if startContext != nil && *startContext != "" {
+		currentContext = *startContext
+		if len(filterArrayOnKeyValue(cfg["contexts"], "name", currentContext).([]interface{})) == 0 {
+			fatalStderrlog.Fatalf("Context '%s' not found in '%s'", currentContext, c.configFile)
+		}
 	}
 	contexts := cfg["contexts"].([]interface{})
+	var rules []policyRuleType
+	if policyFile != nil {
+		rules = loadPolicies(*policyFile)
+	}
 	cs := make([]contextType, 0)
 	for i, ctx := range contexts {
-		cmap := ctx.(map[interface{}]interface{})
-		cluster := c.parseCluster(cfg, cmap["context"])
-		user := c.parseUser(cfg, cmap["context"])
-		ct := contextType{Name: cmap["name"].(string), Cluster: cluster, user: user}
-		mess := fmt.Sprintf("try connecting context '%s', cluster: '%s' ...", ct.Name, cluster.Name)
+		ct := c.parseContext(cfg, ctx)
+		if serviceAccount != nil && *serviceAccount != "" {
+			var err error
+			ct.user, err = c.withServiceAccount(ct, *serviceAccount)
+			if err != nil {
+				fatalStderrlog.Fatalf("Invalid service account: %v", err)
+			}
+		}
+		mess := fmt.Sprintf("authenticating context '%s' with %s", ct.Name, ct.AuthStrategy())
 		fmt.Println(mess)
 		infolog.Print(mess)
-		var skip = false
-		if ct.user.token == "<no value>" {
-			fmt.Print("retrieving token from secret ...")
-			infolog.Print("retrieving token from secret ...")
-			err := retrieveContextToken(&ct)
-			if err != nil {
-				mess := fmt.Sprintf("Skipping context %s, due to error: %s", ct.Name, err.Error())
-				fmt.Println(mess)
-				warninglog.Print(mess)
-				skip = true
+		if ct.user.auth == &anonymousAuthStrategy {
+			warninglog.Printf("no credentials found for user '%s' of context '%s', using anonymous requests", ct.user.Name, ct.Name)
+		}
+		colorIndex := len(cs) % 3
+		ct.color = contextColors[colorIndex]
+		ct.policy = contextPolicy(rules, ct.Name)
+		if ct.readOnly() {
+			ct.color = readOnlyColor
+		}
+		cs = append(cs, ct)
+		mess = fmt.Sprintf("created context no %d with name '%s', policy '%s' ", i+1, ct.Name, ct.policy)
+		fmt.Println(mess)
+		infolog.Print(mess)
+	}
+	c.contexts = cs
+	c.currentContext = 0
+	for i, ct := range cs {
+		if ct.Name == currentContext {
+			c.currentContext = i
+		}
+	}
+	if currentContext != "" && cs[c.currentContext].Name != currentContext {
+		warninglog.Printf("current context '%s' not found, starting with '%s'", currentContext, cs[c.currentContext].Name)
+	}
+	return c
+}
+
+// loadKubeconfig merges the files of the configFile path list: the first file defining a cluster, user, context or the current-context wins
+func (c *configType) loadKubeconfig() map[string]interface{} {
+	merged := map[string]interface{}{}
+	defined := map[string]bool{}
+	for _, file := range filepath.SplitList(c.configFile) {
+		if file == "" {
+			continue
+		}
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			if os.IsNotExist(err) {
+				warninglog.Printf("skipping missing config file '%s'", file)
+				continue
 			}
+			fatalStderrlog.Fatalf("Can't read file %s: %v", file, err.Error())
 		}
-		if !skip {
-			err = c.isAvailable(ct)
-			if err != nil {
-				mess := fmt.Sprintf("Skipping context %s, due to error: %s", ct.Name, err.Error())
-				fmt.Println(mess)
-				warninglog.Print(mess)
-				skip = true
+		mess := fmt.Sprintf("reading clusters config from %s:", file)
+		fmt.Println(mess)
+		tracelog.Print(mess)
+
+		var kc map[string]interface{}
+		err = yaml.Unmarshal(data, &kc)
+		if err != nil {
+			fatalStderrlog.Fatalf("Didn't understand the yaml in file %s: %v", file, err.Error())
+		}
+		resolveLocalPaths(kc, filepath.Dir(file))
+		for _, key := range []string{"clusters", "users", "contexts"} {
+			entries, _ := kc[key].([]interface{})
+			for _, e := range entries {
+				id := key + "/" + configValue(e, "{{.name}}")
+				if defined[id] {
+					tracelog.Printf("%s already defined, ignoring definition in '%s'", id, file)
+					continue
+				}
+				defined[id] = true
+				list, _ := merged[key].([]interface{})
+				merged[key] = append(list, e)
 			}
 		}
-		if !skip {
-			colorIndex := len(cs) % 3
-			ct.color = contextColors[colorIndex]
-			cs = append(cs, ct)
-			mess = fmt.Sprintf("created context no %d with name '%s' ", i+1, ct.Name)
-			fmt.Println(mess)
-			infolog.Print(mess)
+		if merged["current-context"] == nil && configValue(kc, `{{ index . "current-context" }}`) != "" {
+			merged["current-context"] = kc["current-context"]
 		}
 	}
-	if len(cs) == 0 {
-		fatalStderrlog.Fatalf("No contexts created for configfile '%s'. See logfile '%s' for details.", c.configFile, *logFilePath)
+	if len(defined) == 0 {
+		fatalStderrlog.Fatalf("No kubeconfig found in '%s'", c.configFile)
 	}
-	c.contexts = cs
-	return c
+	return merged
 }
 
-func (c *configType) isAvailable(ct contextType) error {
-	be := newBackend(ct)
-	return be.availabiltyCheck()
+// resolveLocalPaths makes file references of kc absolute, they are relative to the directory of the file they are defined in
+func resolveLocalPaths(kc map[string]interface{}, dir string) {
+	resolve := func(entries interface{}, section string, keys ...string) {
+		list, _ := entries.([]interface{})
+		for _, e := range list {
+			em, _ := e.(map[interface{}]interface{})
+			m, _ := em[section].(map[interface{}]interface{})
+			for _, k := range keys {
+				p, _ := m[k].(string)
+				if p != "" && !filepath.IsAbs(p) {
+					m[k] = filepath.Join(dir, p)
+				}
+			}
+			if section == "user" {
+				exec, _ := m["exec"].(map[interface{}]interface{})
+				// like kubectl, only commands with a path are resolved, others are looked up in PATH
+				if cmd, _ := exec["command"].(string); cmd != "" && filepath.Base(cmd) != cmd && !filepath.IsAbs(cmd) {
+					exec["command"] = filepath.Join(dir, cmd)
+				}
+			}
+		}
+	}
+	resolve(kc["clusters"], "cluster", "certificate-authority")
+	resolve(kc["users"], "user", "client-certificate", "client-key", "tokenFile")
+}
+
+func (c *configType) parseContext(cfg map[string]interface{}, ctx interface{}) contextType {
+	cmap := ctx.(map[interface{}]interface{})
+	return contextType{Name: cmap["name"].(string),
+		Cluster:   c.parseCluster(cfg, cmap["context"]),
+		user:      c.parseUser(cfg, cmap["context"]),
+		namespace: configValue(cmap["context"], "{{.namespace}}"),
+		health:    newContextHealth(),
+	}
 }
 
 func (c *configType) parseCluster(cfg map[string]interface{}, cm interface{}) clusterType {
@@ -882,7 +1029,12 @@ func (c *configType) parseCluster(cfg map[string]interface{}, cm interface{}) cl
 	if err != nil {
 		errorlog.Fatalf("error parsing url %v", err)
 	}
-	return clusterType{Name: clusterName, URL: url}
+	return clusterType{Name: clusterName, URL: url,
+		certificateAuthority:     configValue(cluster, `{{ index .cluster "certificate-authority" }}`),
+		certificateAuthorityData: configValue(cluster, `{{ index .cluster "certificate-authority-data" }}`),
+		insecureSkipTLSVerify:    configValue(cluster, `{{ index .cluster "insecure-skip-tls-verify" }}`) == "true",
+		tlsServerName:            configValue(cluster, `{{ index .cluster "tls-server-name" }}`),
+	}
 }
 
 func (c *configType) parseUser(cfg map[string]interface{}, cm interface{}) userType {
@@ -891,8 +1043,70 @@ func (c *configType) parseUser(cfg map[string]interface{}, cm interface{}) userT
 		errorlog.Fatalf("No user found in context %v", cm)
 	}
 	user := filterArrayOnKeyValue(cfg["users"], "name", userName).([]interface{})[0]
-	token := val1(user, "{{.user.token}}")
-	return userType{Name: userName, token: token}
+	u := userType{Name: userName,
+		token:                 configValue(user, "{{.user.token}}"),
+		tokenFile:             configValue(user, "{{.user.tokenFile}}"),
+		clientCertificate:     configValue(user, `{{ index .user "client-certificate" }}`),
+		clientCertificateData: configValue(user, `{{ index .user "client-certificate-data" }}`),
+		clientKey:             configValue(user, `{{ index .user "client-key" }}`),
+		clientKeyData:         configValue(user, `{{ index .user "client-key-data" }}`),
+		exec:                  c.parseExec(user),
+		username:              configValue(user, "{{.user.username}}"),
+		password:              configValue(user, "{{.user.password}}"),
+	}
+	u.auth = selectAuthStrategy(u)
+	return u
+}
+
+// withServiceAccount returns the user of ct authenticating with tokens requested for the service account '<namespace>/<name>'
+func (c *configType) withServiceAccount(ct contextType, sa string) (userType, error) {
+	parts := strings.Split(sa, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return ct.user, fmt.Errorf("'%s' is not in the format '<namespace>/<name>'", sa)
+	}
+	u := ct.user
+	u.serviceAccount = newTokenRequestProvider(ct, parts[0], parts[1])
+	u.auth = selectAuthStrategy(u)
+	return u, nil
+}
+
+func (c *configType) parseExec(user interface{}) *execCredentialProvider {
+	command := configValue(user, "{{.user.exec.command}}")
+	if command == "" {
+		return nil
+	}
+	ec := execConfigType{apiVersion: configValue(user, "{{.user.exec.apiVersion}}"), command: command}
+	exec := user.(map[interface{}]interface{})["user"].(map[interface{}]interface{})["exec"].(map[interface{}]interface{})
+	if args, ok := exec["args"].([]interface{}); ok {
+		for _, a := range args {
+			ec.args = append(ec.args, fmt.Sprint(a))
+		}
+	}
+	if env, ok := exec["env"].([]interface{}); ok {
+		for _, e := range env {
+			ec.env = append(ec.env, fmt.Sprintf("%s=%s", configValue(e, "{{.name}}"), configValue(e, "{{.value}}")))
+		}
+	}
+	return newExecCredentialProvider(ec)
+}
+
+// configValue evaluates path like val1, but returns an empty string for missing or unreadable values
+func configValue(node interface{}, path string) string {
+	buf := new(bytes.Buffer)
+	if err := tplNoFunc(path, path).Execute(buf, node); err != nil {
+		return ""
+	}
+	if buf.String() == "<no value>" {
+		return ""
+	}
+	return buf.String()
+}
+
+func defaultConfigFile() string {
+	if kc := os.Getenv("KUBECONFIG"); kc != "" {
+		return kc
+	}
+	return filepath.Join(homeDir(), ".kube", "config")
 }
 
 func homeDir() string {
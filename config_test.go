@@ -0,0 +1,71 @@
+package kubexp
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func names(entries interface{}) []string {
+	r := []string{}
+	for _, e := range entries.([]interface{}) {
+		r = append(r, configValue(e, "{{.name}}"))
+	}
+	return r
+}
+
+func Test_KubeconfigMerge(t *testing.T) {
+	require := require.New(t)
+	dir := filepath.Join("testdata", "merge")
+	c := &configType{configFile: strings.Join([]string{filepath.Join(dir, "dev"), filepath.Join(dir, "missing"), filepath.Join(dir, "prod")}, string(os.PathListSeparator))}
+	kc := c.loadKubeconfig()
+
+	require.Equal([]string{"dev", "shared", "prod"}, names(kc["clusters"]))
+	require.Equal([]string{"dev-admin", "prod-user"}, names(kc["users"]))
+	require.Equal([]string{"dev", "prod", "shared"}, names(kc["contexts"]))
+	require.Equal("dev", configValue(kc, `{{ index . "current-context" }}`))
+
+	shared := c.parseCluster(kc, map[interface{}]interface{}{"cluster": "shared"})
+	require.Equal("https://shared-from-dev.example.com:6443", shared.URL.String(), "first definition wins")
+}
+
+func Test_KubeconfigRelativePaths(t *testing.T) {
+	require := require.New(t)
+	dir := filepath.Join("testdata", "merge")
+	c := &configType{configFile: filepath.Join(dir, "prod") + string(os.PathListSeparator) + filepath.Join(dir, "dev")}
+	kc := c.loadKubeconfig()
+	require.Equal("prod", configValue(kc, `{{ index . "current-context" }}`))
+
+	dev := c.parseCluster(kc, map[interface{}]interface{}{"cluster": "dev"})
+	require.Equal(filepath.Join(dir, "certs", "dev-ca.crt"), dev.certificateAuthority)
+
+	admin := c.parseUser(kc, map[interface{}]interface{}{"user": "dev-admin"})
+	require.Equal(filepath.Join(dir, "certs", "dev-admin.crt"), admin.clientCertificate)
+	require.Equal(filepath.Join(dir, "certs", "dev-admin.key"), admin.clientKey)
+	require.Equal("clientCertificate", admin.authStrategyName())
+
+	prodUser := c.parseUser(kc, map[interface{}]interface{}{"user": "prod-user"})
+	require.Equal(filepath.Join(dir, "bin", "get-token"), prodUser.exec.config.command)
+}
+
+func Test_ContextNamespace(t *testing.T) {
+	require := require.New(t)
+	c := &configType{configFile: filepath.Join("testdata", "merge", "dev") + string(os.PathListSeparator) + filepath.Join("testdata", "merge", "prod")}
+	kc := c.loadKubeconfig()
+	contexts := kc["contexts"].([]interface{})
+
+	dev := c.parseContext(kc, contexts[0])
+	require.Equal("dev", dev.Name)
+	require.Equal("team-a", dev.defaultNamespace())
+	prod := c.parseContext(kc, contexts[1])
+	require.Equal("", prod.defaultNamespace())
+
+	ns := "override"
+	startNamespace = &ns
+	defer func() { startNamespace = nil }()
+	require.Equal("override", dev.defaultNamespace())
+	require.Equal("override", prod.defaultNamespace())
+}
@@ -0,0 +1,285 @@
+package kubexp
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// crdResource resource of the custom resource definition, its list view shows the additionalPrinterColumns of the served version.
+// The version is taken from apiPrefix if it is given, e.g. the preferred version found by the api discovery
+func crdResource(crd interface{}, apiPrefix string) (resourceType, bool) {
+	group := configValue(crd, "{{.spec.group}}")
+	plural := configValue(crd, "{{.spec.names.plural}}")
+	if group == "" || plural == "" {
+		return resourceType{}, false
+	}
+	spec, _ := crd.(map[string]interface{})["spec"].(map[string]interface{})
+	version, columns := crdVersion(spec, strings.TrimPrefix(apiPrefix, "apis/"+group+"/"))
+	if version == "" {
+		return resourceType{}, false
+	}
+	res := resourceType{Name: plural, ShortName: plural, Category: "custom", APIPrefix: "apis/" + group + "/" + version,
+		Namespace: configValue(crd, "{{.spec.scope}}") == "Namespaced", Watch: true,
+		Views: []viewType{{Name: "list", Template: printerColumnsTemplate(columns)}, infoView, yamlView, jsonView},
+	}
+	if shortName := jsonPath(crd, ".spec.names.shortNames[0]"); shortName != "" {
+		res.ShortName = shortName
+	}
+	return res, true
+}
+
+// crdVersion the served version with name preferred, otherwise the storage version or the first served one, together with its printer columns
+func crdVersion(spec map[string]interface{}, preferred string) (string, []interface{}) {
+	versions, _ := spec["versions"].([]interface{})
+	if len(versions) == 0 {
+		// apiextensions.k8s.io/v1beta1 with a single version and the columns in the spec
+		version, _ := spec["version"].(string)
+		columns, _ := spec["additionalPrinterColumns"].([]interface{})
+		return version, columns
+	}
+	selected := map[string]interface{}(nil)
+	for _, v := range versions {
+		version, ok := v.(map[string]interface{})
+		if !ok || version["served"] != true {
+			continue
+		}
+		switch {
+		case version["name"] == preferred:
+			selected = version
+		case selected == nil, version["storage"] == true && selected["name"] != preferred:
+			selected = version
+		}
+	}
+	if selected == nil {
+		return "", nil
+	}
+	columns, ok := selected["additionalPrinterColumns"].([]interface{})
+	if !ok {
+		columns, _ = spec["additionalPrinterColumns"].([]interface{})
+	}
+	return fmt.Sprintf("%v", selected["name"]), columns
+}
+
+// printerColumnsTemplate list view with name, age and the printer columns, columns shown only in wide output (priority > 0) are left out
+func printerColumnsTemplate(columns []interface{}) string {
+	tpl := nameAgeColumns
+	for _, c := range columns {
+		column, ok := c.(map[string]interface{})
+		if !ok || column["priority"] != nil && column["priority"] != float64(0) {
+			continue
+		}
+		name, _ := column["name"].(string)
+		path, _ := column["jsonPath"].(string)
+		if path == "" {
+			path, _ = column["JSONPath"].(string)
+		}
+		if name == "" || path == "" || path == ".metadata.creationTimestamp" {
+			continue
+		}
+		value := fmt.Sprintf("(jsonPath . %q)", path)
+		width := 20
+		switch column["type"] {
+		case "date":
+			value = fmt.Sprintf("(jsonPath . %q | age)", path)
+			width = 8
+		case "integer", "number":
+			width = 10
+		case "boolean":
+			width = 8
+		}
+		if len(name) > width {
+			width = len(name)
+		}
+		tpl += fmt.Sprintf("\n{{- header %q . %s | printf \"%%-%d.%ds \" -}}", name, value, width, width)
+	}
+	return tpl
+}
+
+// applyCustomResources sets the resources of the custom resource definitions crds, they replace the generic resources of the api discovery.
+// Configured resources are kept, resources of deleted definitions are removed
+func (c *configType) applyCustomResources(crds []interface{}) {
+	custom := map[string]resourceType{}
+	order := []string{}
+	for _, crd := range crds {
+		res, ok := crdResource(crd, c.apiPrefixOf(configValue(crd, "{{.spec.names.plural}}"), configValue(crd, "{{.spec.group}}")))
+		if !ok {
+			continue
+		}
+		key := res.key()
+		custom[key] = res
+		order = append(order, key)
+	}
+	resources := []resourceType{}
+	for _, res := range c.apiResources {
+		key := res.key()
+		cr, isCustom := custom[key]
+		switch {
+		case c.isConfigured(key):
+			resources = append(resources, res)
+		case isCustom:
+			resources = append(resources, cr)
+			delete(custom, key)
+		case !c.customResourceKeys[key]:
+			resources = append(resources, res)
+		}
+	}
+	if c.customResourceKeys == nil {
+		c.customResourceKeys = map[string]bool{}
+	}
+	for _, key := range order {
+		c.customResourceKeys[key] = true
+		if res, ok := custom[key]; ok && !c.isConfigured(key) {
+			resources = append(resources, res)
+		}
+	}
+	c.resources = resources
+}
+
+func (c *configType) apiPrefixOf(resName, group string) string {
+	for _, res := range c.apiResources {
+		if res.Name == resName && apiGroupOf(res.APIPrefix) == group {
+			return res.APIPrefix
+		}
+	}
+	return ""
+}
+
+func (c *configType) isConfigured(key string) bool {
+	for _, res := range c.configuredResources {
+		if res.key() == key {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonPath values of the kubectl JSONPath expression, e.g. '.status.conditions[?(@.type=="Ready")].status', separated by comma
+func jsonPath(it interface{}, path string) string {
+	path = strings.TrimSuffix(strings.TrimPrefix(path, "{"), "}")
+	strs := []string{}
+	for _, v := range evalJSONPath([]interface{}{it}, path) {
+		strs = append(strs, jsonPathString(v))
+	}
+	return strings.Join(strs, ",")
+}
+
+func evalJSONPath(values []interface{}, path string) []interface{} {
+	for path != "" {
+		next := []interface{}{}
+		switch path[0] {
+		case '.':
+			end := strings.IndexAny(path[1:], ".[") + 1
+			if end == 0 {
+				end = len(path)
+			}
+			field := path[1:end]
+			path = path[end:]
+			if field == "" {
+				continue
+			}
+			for _, v := range values {
+				if m, ok := v.(map[string]interface{}); ok {
+					if fv, found := m[field]; found {
+						next = append(next, fv)
+					}
+				}
+			}
+		case '[':
+			end := strings.Index(path, "]")
+			if strings.HasPrefix(path, "[?(") {
+				end = strings.Index(path, ")]") + 1
+			}
+			if end <= 0 {
+				return nil
+			}
+			selector := path[1:end]
+			path = path[end+1:]
+			for _, v := range values {
+				next = append(next, selectJSONPath(v, selector)...)
+			}
+		default:
+			path = "." + path
+			continue
+		}
+		values = next
+	}
+	return values
+}
+
+// selectJSONPath elements of the array or map v selected by '*', an index or a filter like '?(@.type=="Ready")'
+func selectJSONPath(v interface{}, selector string) []interface{} {
+	elements := []interface{}{}
+	switch typed := v.(type) {
+	case []interface{}:
+		elements = typed
+	case map[string]interface{}:
+		if selector != "*" {
+			return nil
+		}
+		for _, e := range typed {
+			elements = append(elements, e)
+		}
+	}
+	switch {
+	case selector == "*":
+		return elements
+	case strings.HasPrefix(selector, "?(") && strings.HasSuffix(selector, ")"):
+		filter := selector[2 : len(selector)-1]
+		op := ""
+		for _, o := range []string{"==", "!="} {
+			if strings.Contains(filter, o) {
+				op = o
+				break
+			}
+		}
+		left, right := filter, ""
+		if op != "" {
+			parts := strings.SplitN(filter, op, 2)
+			left, right = strings.TrimSpace(parts[0]), strings.Trim(strings.TrimSpace(parts[1]), `"'`)
+		}
+		selected := []interface{}{}
+		for _, e := range elements {
+			values := evalJSONPath([]interface{}{e}, strings.TrimPrefix(left, "@"))
+			matches := len(values) > 0
+			if op != "" {
+				matches = matches && (jsonPathString(values[0]) == right) == (op == "==")
+			}
+			if matches {
+				selected = append(selected, e)
+			}
+		}
+		return selected
+	default:
+		i, err := strconv.Atoi(selector)
+		if err != nil {
+			return nil
+		}
+		if i < 0 {
+			i += len(elements)
+		}
+		if i < 0 || i >= len(elements) {
+			return nil
+		}
+		return []interface{}{elements[i]}
+	}
+}
+
+func jsonPathString(v interface{}) string {
+	switch typed := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return typed
+	case float64:
+		return strconv.FormatFloat(typed, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(typed)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(b)
+}
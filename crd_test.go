@@ -0,0 +1,88 @@
+package kubexp
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testCRD(group, plural, scope string) interface{} {
+	return unmarshall(fmt.Sprintf(`{"kind":"CustomResourceDefinition","metadata":{"name":"%s.%s"},"spec":{"group":"%s","scope":"%s","names":{"plural":"%s","kind":"Widget","shortNames":["wd"]},
+	"versions":[
+		{"name":"v1alpha1","served":true,"storage":false},
+		{"name":"v1","served":true,"storage":true,"additionalPrinterColumns":[
+			{"name":"Ready","type":"string","jsonPath":".status.conditions[?(@.type==\"Ready\")].status"},
+			{"name":"Replicas","type":"integer","jsonPath":".spec.replicas"},
+			{"name":"Internal","type":"string","jsonPath":".spec.internal","priority":1},
+			{"name":"Age","type":"date","jsonPath":".metadata.creationTimestamp"}]},
+		{"name":"v0","served":false,"storage":false}]}}`, plural, group, group, scope, plural))
+}
+
+func Test_JSONPath(t *testing.T) {
+	require := require.New(t)
+	item := unmarshall(`{"spec":{"replicas":3,"paused":false,"hosts":["a","b"],"tls":{"enabled":true}},
+		"status":{"conditions":[{"type":"Synced","status":"False"},{"type":"Ready","status":"True"}]}}`)
+	require.Equal("3", jsonPath(item, ".spec.replicas"))
+	require.Equal("3", jsonPath(item, "{.spec.replicas}"))
+	require.Equal("false", jsonPath(item, ".spec.paused"))
+	require.Equal("a,b", jsonPath(item, ".spec.hosts[*]"))
+	require.Equal("b", jsonPath(item, ".spec.hosts[-1]"))
+	require.Equal(`{"enabled":true}`, jsonPath(item, ".spec.tls"))
+	require.Equal("True", jsonPath(item, `.status.conditions[?(@.type=="Ready")].status`))
+	require.Equal("Synced", jsonPath(item, `.status.conditions[?(@.status!='True')].type`))
+	require.Equal("", jsonPath(item, ".spec.missing.deeper"))
+	require.Equal("", jsonPath(item, ".spec.hosts[5]"))
+}
+
+func Test_CRDResource(t *testing.T) {
+	require := require.New(t)
+	res, ok := crdResource(testCRD("example.com", "widgets", "Namespaced"), "")
+	require.True(ok)
+	require.Equal("widgets", res.Name)
+	require.Equal("wd", res.ShortName)
+	require.Equal("custom", res.Category)
+	require.Equal("apis/example.com/v1", res.APIPrefix, "storage version")
+	require.True(res.Namespace)
+
+	item := unmarshall(`{"metadata":{"name":"w1"},"spec":{"replicas":2,"internal":"x"},"status":{"conditions":[{"type":"Ready","status":"True"}]}}`)
+	checkTpl(t, res.Views[0].Template, item, fmt.Sprintf("%-50.50s %-8.8s %-20.20s %-10.10s ", "w1", "", "True", "2"))
+	header := map[string]interface{}{"header": "true"}
+	checkTpl(t, res.Views[0].Template, header, fmt.Sprintf("%-50.50s %-8.8s %-20.20s %-10.10s ", "Name", "Age", "Ready", "Replicas"))
+
+	res, _ = crdResource(testCRD("example.com", "widgets", "Cluster"), "apis/example.com/v1alpha1")
+	require.Equal("apis/example.com/v1alpha1", res.APIPrefix, "preferred version of the discovery")
+	require.Equal(nameAgeColumns, res.Views[0].Template, "version without printer columns")
+	require.False(res.Namespace)
+
+	_, ok = crdResource(unmarshall(`{"spec":{"group":"example.com","names":{"plural":"gadgets"},"versions":[{"name":"v1","served":false}]}}`), "")
+	require.False(ok, "no served version")
+}
+
+func Test_ApplyCustomResources(t *testing.T) {
+	require := require.New(t)
+	generic := func(name string) resourceType {
+		return resourceType{Name: name, APIPrefix: "apis/example.com/v1", Category: "namespace/metadata", Namespace: true, Watch: true}
+	}
+	cfg := &configType{
+		configuredResources: []resourceType{podResource},
+		apiResources:        []resourceType{podResource, generic("widgets"), generic("gadgets")},
+	}
+	names := func() []string {
+		names := []string{}
+		for _, res := range cfg.resources {
+			names = append(names, res.Category+":"+res.Name)
+		}
+		return names
+	}
+
+	cfg.applyCustomResources([]interface{}{testCRD("example.com", "widgets", "Namespaced"), testCRD("example.com", "gadgets", "Namespaced")})
+	require.Equal([]string{":pods", "custom:widgets", "custom:gadgets"}, names())
+
+	cfg.applyCustomResources([]interface{}{testCRD("example.com", "widgets", "Namespaced"), testCRD("example.org", "things", "Cluster")})
+	require.Equal([]string{":pods", "custom:widgets", "custom:things"}, names(), "deleted definition is removed, new one added")
+	require.Equal("apis/example.org/v1", cfg.resourcesOfName("things").APIPrefix)
+
+	cfg.applyCustomResources([]interface{}{testCRD("", "pods", "Namespaced")})
+	require.Equal([]string{":pods"}, names())
+}
@@ -0,0 +1,231 @@
+package kubexp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// apiResourceType resource served by the api server in the preferred version of its group
+type apiResourceType struct {
+	group, groupVersion string
+	name, kind          string
+	shortNames          []string
+	namespaced          bool
+	verbs               []string
+}
+
+func (ar apiResourceType) apiPrefix() string {
+	if ar.group == "" {
+		return "api/" + ar.groupVersion
+	}
+	return "apis/" + ar.groupVersion
+}
+
+func (ar apiResourceType) hasVerb(verb string) bool {
+	for _, v := range ar.verbs {
+		if v == verb {
+			return true
+		}
+	}
+	return false
+}
+
+type apiResourceListType struct {
+	GroupVersion string `json:"groupVersion"`
+	Resources    []struct {
+		Name       string   `json:"name"`
+		Kind       string   `json:"kind"`
+		Namespaced bool     `json:"namespaced"`
+		ShortNames []string `json:"shortNames"`
+		Verbs      []string `json:"verbs"`
+	} `json:"resources"`
+}
+
+// discover lists the resources of the core api and the preferred versions of all api groups, subresources and resources which can't be listed are left out
+// GET /api
+// GET /api/v1
+// GET /apis
+// GET /apis/batch/v1
+func (b *backendType) discover() ([]apiResourceType, error) {
+	var core struct {
+		Versions []string `json:"versions"`
+	}
+	if err := b.getJSON("api", &core); err != nil {
+		return nil, err
+	}
+	var groups struct {
+		Groups []struct {
+			Name             string `json:"name"`
+			PreferredVersion struct {
+				GroupVersion string `json:"groupVersion"`
+			} `json:"preferredVersion"`
+		} `json:"groups"`
+	}
+	if err := b.getJSON("apis", &groups); err != nil {
+		return nil, err
+	}
+	paths := []string{}
+	if len(core.Versions) > 0 {
+		paths = append(paths, "api/"+core.Versions[0])
+	}
+	for _, g := range groups.Groups {
+		paths = append(paths, "apis/"+g.PreferredVersion.GroupVersion)
+	}
+
+	lists := make([]apiResourceListType, len(paths))
+	errs := make([]error, len(paths))
+	var wg sync.WaitGroup
+	for i, p := range paths {
+		wg.Add(1)
+		go func(i int, p string) {
+			defer wg.Done()
+			errs[i] = b.getJSON(p, &lists[i])
+		}(i, p)
+	}
+	wg.Wait()
+
+	resources := []apiResourceType{}
+	for i, list := range lists {
+		if errs[i] != nil {
+			// an unavailable aggregated api must not hide the others
+			warninglog.Printf("can't discover resources of '%s': %v", paths[i], errs[i])
+			continue
+		}
+		group := ""
+		if strings.Contains(list.GroupVersion, "/") {
+			group = strings.SplitN(list.GroupVersion, "/", 2)[0]
+		}
+		for _, r := range list.Resources {
+			ar := apiResourceType{group: group, groupVersion: list.GroupVersion, name: r.Name, kind: r.Kind, shortNames: r.ShortNames, namespaced: r.Namespaced, verbs: r.Verbs}
+			if strings.Contains(ar.name, "/") || !ar.hasVerb("list") {
+				continue
+			}
+			resources = append(resources, ar)
+		}
+	}
+	tracelog.Printf("discovered %d resources in %d api group versions", len(resources), len(paths))
+	return resources, nil
+}
+
+func (b *backendType) getJSON(path string, v interface{}) error {
+	url := fmt.Sprintf("%s/%s", b.context.Cluster.URL, path)
+	resp, err := b.restExecutor(http.MethodGet, url, "", restCallTimeout, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: http status %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// apiGroupOf group of an api prefix, e.g. 'batch' for 'apis/batch/v1' and "" for the core api 'api/v1'
+func apiGroupOf(apiPrefix string) string {
+	parts := strings.Split(apiPrefix, "/")
+	if len(parts) < 3 || parts[0] != "apis" {
+		return ""
+	}
+	return parts[1]
+}
+
+// discoverResources resolves the configured resources to the versions served by the api server of the backend and adds all other resources
+func (c *configType) discoverResources(be *backendType) {
+	api, err := be.discover()
+	c.apiKinds = map[string]apiResourceType{}
+	if err != nil {
+		warninglog.Printf("api discovery failed, using configured resources: %v", err)
+		c.apiResources = c.configuredResources
+	} else {
+		c.apiResources = c.resolveResources(api)
+		for _, ar := range api {
+			if _, found := c.apiKinds[ar.group+"/"+ar.kind]; !found {
+				c.apiKinds[ar.group+"/"+ar.kind] = ar
+			}
+		}
+	}
+	c.resources = c.apiResources
+	c.customResourceKeys = nil
+}
+
+// resolveResources configured resources are taken in the preferred version of their group. A configured resource of a built-in group is also taken
+// from another built-in group serving a resource with the same name, e.g. ingresses moved from extensions to networking.k8s.io.
+// Configured resources which are not served are left out, the other served resources are added with generic views
+func (c *configType) resolveResources(api []apiResourceType) []resourceType {
+	byKey := map[string]apiResourceType{}
+	byName := map[string][]apiResourceType{}
+	for _, ar := range api {
+		byKey[ar.group+"/"+ar.name] = ar
+		byName[ar.name] = append(byName[ar.name], ar)
+	}
+	resolved := []resourceType{}
+	known := map[string]bool{}
+	for _, res := range c.configuredResources {
+		group := apiGroupOf(res.APIPrefix)
+		ar, found := byKey[group+"/"+res.Name]
+		if !found && isBuiltinGroup(group) {
+			for _, candidate := range byName[res.Name] {
+				if isBuiltinGroup(candidate.group) && !known[candidate.group+"/"+candidate.name] {
+					ar, found = candidate, true
+					break
+				}
+			}
+		}
+		if !found || known[ar.group+"/"+ar.name] {
+			infolog.Printf("resource %s is not served by the api server", res.key())
+			continue
+		}
+		if res.APIPrefix != ar.apiPrefix() {
+			tracelog.Printf("resource %s is served with '%s' instead of '%s'", res.Name, ar.apiPrefix(), res.APIPrefix)
+		}
+		res.APIPrefix = ar.apiPrefix()
+		res.Namespace = ar.namespaced
+		res.Watch = ar.hasVerb("watch")
+		resolved = append(resolved, res)
+		known[ar.group+"/"+ar.name] = true
+	}
+	for _, ar := range api {
+		if known[ar.group+"/"+ar.name] {
+			continue
+		}
+		known[ar.group+"/"+ar.name] = true
+		resolved = append(resolved, genericResource(ar))
+	}
+	return resolved
+}
+
+// isBuiltinGroup the api group is one of kubernetes, the core api, e.g. 'apps', or a group ending with '.k8s.io'. Custom resources have other groups
+func isBuiltinGroup(group string) bool {
+	return !strings.Contains(group, ".") || strings.HasSuffix(group, ".k8s.io")
+}
+
+func genericResource(ar apiResourceType) resourceType {
+	res := resourceType{Name: ar.name, ShortName: ar.name, APIPrefix: ar.apiPrefix(), Namespace: ar.namespaced, Watch: ar.hasVerb("watch"),
+		Category: "cluster/metadata",
+		Views:    []viewType{{Name: "list", Template: nameAgeColumns}, infoView, yamlView, jsonView},
+	}
+	if len(ar.shortNames) > 0 {
+		res.ShortName = ar.shortNames[0]
+	}
+	if ar.namespaced {
+		res.Category = "namespace/metadata"
+	}
+	return res
+}
+
+// resourceOfKind the resource of the objects with the apiVersion and kind, in the version given by apiVersion
+func (c *configType) resourceOfKind(apiVersion, kind string) (resourceType, bool) {
+	group := ""
+	if strings.Contains(apiVersion, "/") {
+		group = strings.SplitN(apiVersion, "/", 2)[0]
+	}
+	ar, found := c.apiKinds[group+"/"+kind]
+	if !found {
+		return resourceType{}, false
+	}
+	ar.groupVersion = apiVersion
+	return resourceType{Name: ar.name, APIPrefix: ar.apiPrefix(), Namespace: ar.namespaced}, true
+}
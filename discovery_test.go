@@ -0,0 +1,121 @@
+package kubexp
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var testDiscovery = map[string]string{
+	"/api":  `{"kind":"APIVersions","versions":["v1"]}`,
+	"/apis": `{"kind":"APIGroupList","groups":[{"name":"batch","preferredVersion":{"groupVersion":"batch/v1"}},{"name":"networking.k8s.io","preferredVersion":{"groupVersion":"networking.k8s.io/v1"}},{"name":"example.com","preferredVersion":{"groupVersion":"example.com/v2"}}]}`,
+	"/api/v1": `{"kind":"APIResourceList","groupVersion":"v1","resources":[
+		{"name":"pods","namespaced":true,"kind":"Pod","verbs":["get","list","watch","delete"],"shortNames":["po"]},
+		{"name":"pods/log","namespaced":true,"kind":"Pod","verbs":["get"]},
+		{"name":"bindings","namespaced":true,"kind":"Binding","verbs":["create"]},
+		{"name":"componentstatuses","namespaced":false,"kind":"ComponentStatus","verbs":["get","list"],"shortNames":["cs"]}]}`,
+	"/apis/batch/v1": `{"kind":"APIResourceList","groupVersion":"batch/v1","resources":[
+		{"name":"cronjobs","namespaced":true,"kind":"CronJob","verbs":["get","list","watch"],"shortNames":["cj"]}]}`,
+	"/apis/networking.k8s.io/v1": `{"kind":"APIResourceList","groupVersion":"networking.k8s.io/v1","resources":[
+		{"name":"ingresses","namespaced":true,"kind":"Ingress","verbs":["get","list","watch"],"shortNames":["ing"]}]}`,
+	"/apis/example.com/v2": `{"kind":"APIResourceList","groupVersion":"example.com/v2","resources":[
+		{"name":"widgets","namespaced":true,"kind":"Widget","verbs":["get","list","watch"],"shortNames":["wd"]},
+		{"name":"gadgets","namespaced":false,"kind":"Gadget","verbs":["get","list","watch"]}]}`,
+}
+
+func Test_DiscoverResources(t *testing.T) {
+	require := require.New(t)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, found := testDiscovery[r.URL.Path]
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, body)
+	}))
+	defer server.Close()
+
+	listView := viewType{Name: "list", Template: "configured"}
+	cfg := &configType{configuredResources: []resourceType{
+		{Name: "pods", APIPrefix: "api/v1", ShortName: "po", Namespace: true, Watch: true, Views: []viewType{listView}},
+		{Name: "cronjobs", APIPrefix: "apis/batch/v1beta1", ShortName: "cron", Namespace: true, Watch: true, Views: []viewType{listView}},
+		{Name: "ingresses", APIPrefix: "apis/extensions/v1beta1", ShortName: "ing", Namespace: true, Watch: true, Views: []viewType{listView}},
+		{Name: "storageclasses", APIPrefix: "apis/storage.k8s.io/v1beta1", ShortName: "sc", Watch: true, Views: []viewType{listView}},
+	}}
+	cfg.discoverResources(newTestWatchBackend(server))
+
+	byName := map[string]resourceType{}
+	names := []string{}
+	for _, res := range cfg.resources {
+		byName[res.Name] = res
+		names = append(names, res.Name)
+	}
+	require.Equal([]string{"pods", "cronjobs", "ingresses", "componentstatuses", "widgets", "gadgets"}, names, "configured resources first, subresources, unlistable and unserved resources left out")
+	require.Equal("apis/batch/v1", byName["cronjobs"].APIPrefix)
+	require.Equal("apis/networking.k8s.io/v1", byName["ingresses"].APIPrefix, "served by another group")
+	require.Equal([]viewType{listView}, byName["ingresses"].Views, "configured views are kept")
+	require.Equal("cron", byName["cronjobs"].ShortName)
+
+	require.False(byName["componentstatuses"].Watch)
+	require.Equal("cs", byName["componentstatuses"].ShortName)
+	require.Equal("cluster/metadata", byName["componentstatuses"].Category)
+	require.Equal("apis/example.com/v2", byName["widgets"].APIPrefix)
+	require.True(byName["widgets"].Namespace)
+	require.Equal("namespace/metadata", byName["widgets"].Category)
+	require.Equal("list", byName["widgets"].Views[0].Name)
+	require.Equal("gadgets", byName["gadgets"].ShortName)
+	require.False(byName["gadgets"].Namespace)
+
+	res, found := cfg.resourceOfKind("example.com/v1", "Widget")
+	require.True(found)
+	require.Equal(resourceType{Name: "widgets", APIPrefix: "apis/example.com/v1", Namespace: true}, res)
+	res, found = cfg.resourceOfKind("v1", "Pod")
+	require.True(found)
+	require.Equal("api/v1", res.APIPrefix)
+	_, found = cfg.resourceOfKind("apps/v1", "Deployment")
+	require.False(found)
+}
+
+func Test_DiscoverResourcesFailsOverToConfigured(t *testing.T) {
+	require := require.New(t)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	cfg := &configType{configuredResources: []resourceType{podResource}}
+	cfg.discoverResources(newTestWatchBackend(server))
+	require.Equal([]resourceType{podResource}, cfg.resources)
+}
+
+func Test_ResolveResourcesOfSameName(t *testing.T) {
+	require := require.New(t)
+	api := []apiResourceType{
+		{group: "networking.k8s.io", groupVersion: "networking.k8s.io/v1", name: "networkpolicies", kind: "NetworkPolicy", namespaced: true, verbs: []string{"list", "watch"}},
+		{group: "crd.projectcalico.org", groupVersion: "crd.projectcalico.org/v1", name: "networkpolicies", kind: "NetworkPolicy", namespaced: true, verbs: []string{"list", "watch"}},
+		{group: "example.com", groupVersion: "example.com/v1", name: "podsecuritypolicies", kind: "PodSecurityPolicy", verbs: []string{"list", "watch"}},
+	}
+	listView := viewType{Name: "list", Template: "configured"}
+	cfg := &configType{configuredResources: []resourceType{
+		{Name: "networkpolicies", APIPrefix: "apis/networking.k8s.io/v1", Namespace: true, Watch: true, Views: []viewType{listView}},
+		{Name: "podsecuritypolicies", APIPrefix: "apis/policy/v1beta1", Watch: true, Views: []viewType{listView}},
+	}}
+	cfg.apiResources = cfg.resolveResources(api)
+	keys := []string{}
+	for _, res := range cfg.apiResources {
+		keys = append(keys, res.key())
+	}
+	require.Equal([]string{"networking.k8s.io/networkpolicies", "crd.projectcalico.org/networkpolicies", "example.com/podsecuritypolicies"}, keys, "resources of the same name in other groups are kept, configured resources aren't bound to custom groups")
+	require.Equal([]viewType{listView}, cfg.apiResources[0].Views)
+	require.Equal("list", cfg.apiResources[1].Views[0].Name)
+	require.NotEqual([]viewType{listView}, cfg.apiResources[2].Views)
+
+	cfg.applyCustomResources([]interface{}{testCRD("crd.projectcalico.org", "networkpolicies", "Namespaced")})
+	require.Len(cfg.resources, 3)
+	require.Equal([]viewType{listView}, cfg.resources[0].Views, "configured resource is kept")
+	require.Equal("custom", cfg.resources[1].Category, "custom resource of the same name replaces the generic one")
+	require.Equal("apis/crd.projectcalico.org/v1", cfg.resources[1].APIPrefix)
+}
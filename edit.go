@@ -0,0 +1,271 @@
+package kubexp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// editType edit of a resource item in the editor. The edited manifest is kept, when the api server rejects it
+type editType struct {
+	res             resourceType
+	namespace, name string
+	// resourceVersion and original of the live item, they are fetched again after a conflict
+	resourceVersion string
+	original        string
+	// fileVersion resource version written into the file, a different one was set by the user
+	fileVersion string
+	// rejected manifest with the error on top, as written into the file at rejectedAt
+	rejected   string
+	rejectedAt time.Time
+	file       string
+	cmd        *exec.Cmd
+	// edited manifest and the body to put, after it passed the dry run
+	manifest, body string
+	// preview diff of the original and the result of the dry run
+	preview []string
+	// err of the rejected edit, diff of the applied one
+	err       error
+	diff      []string
+	discarded bool
+}
+
+// pendingEdit edit in the editor, or rejected and waiting to be edited again or discarded
+var pendingEdit *editType
+
+// editHeader first line of the comment on top of a rejected manifest, the comment is removed before it is applied again
+const editHeader = "# The edit was rejected, fix the manifest or leave the editor without saving to discard it:"
+
+// maximum of changed lines shown after an edit
+var editSummaryMaxLines = 30
+
+// newEdit fetches the resource item and writes it as yaml into a temporary file
+func newEdit(be *backendType, res resourceType, ns, name string) (*editType, error) {
+	e := &editType{res: res, namespace: ns, name: name}
+	if err := e.fetch(be); err != nil {
+		return nil, err
+	}
+	f, err := ioutil.TempFile("", fmt.Sprintf("kubexp-%s-%s-*.yaml", res.Name, name))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if _, err := f.WriteString(e.original); err != nil {
+		return nil, err
+	}
+	e.file, e.fileVersion = f.Name(), e.resourceVersion
+	return e, nil
+}
+
+// fetch gets the live item, the edit is put with its resource version
+func (e *editType) fetch(be *backendType) error {
+	obj, err := be.get(e.namespace, e.res, e.name)
+	if err != nil {
+		return err
+	}
+	if meta, ok := obj["metadata"].(map[string]interface{}); ok {
+		delete(meta, "managedFields")
+	}
+	data, err := yaml.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	e.original, e.resourceVersion = string(data), resItemResourceVersion(obj)
+	return nil
+}
+
+// editorCmd opens the file in $EDITOR, which may contain arguments, e.g. 'code --wait'
+func (e *editType) editorCmd() *exec.Cmd {
+	editor := strings.Fields(os.Getenv("EDITOR"))
+	if len(editor) == 0 {
+		editor = []string{"vi"}
+		if runtime.GOOS == "windows" {
+			editor = []string{"notepad"}
+		}
+	}
+	e.cmd = execCommand(editor[0], append(editor[1:], e.file)...)
+	return e.cmd
+}
+
+// edited manifest without the comment of a previous rejection
+func (e *editType) edited() (string, error) {
+	data, err := ioutil.ReadFile(e.file)
+	if err != nil {
+		return "", err
+	}
+	text := string(data)
+	if e.rejected != "" && text == e.rejected {
+		// saving the unchanged manifest puts it again, e.g. after a conflict
+		if fi, err := os.Stat(e.file); err != nil || fi.ModTime().Equal(e.rejectedAt) {
+			e.discarded = true
+			return text, nil
+		}
+	}
+	if strings.HasPrefix(text, editHeader) {
+		lines := strings.Split(text, "\n")
+		i := 0
+		for i < len(lines) && strings.HasPrefix(lines[i], "#") {
+			i++
+		}
+		text = strings.Join(lines[i:], "\n")
+	}
+	return text, nil
+}
+
+// prepare validates the edited manifest with a dry run put with the resource version of the live item, unless the user changed it. An unchanged manifest isn't put
+func (e *editType) prepare(be *backendType) {
+	e.err, e.diff, e.preview, e.body = nil, nil, nil, ""
+	edited, err := e.edited()
+	if err != nil {
+		e.err = err
+		return
+	}
+	if e.discarded {
+		return
+	}
+	e.diff = diffLines(e.original, edited)
+	if len(e.diff) == 0 {
+		return
+	}
+	var obj interface{}
+	if err := yaml.Unmarshal([]byte(edited), &obj); err != nil {
+		e.reject(edited, fmt.Errorf("invalid yaml: %v", err))
+		return
+	}
+	m, ok := jsonCompatible(obj).(map[string]interface{})
+	if !ok {
+		e.reject(edited, fmt.Errorf("the manifest is not an object"))
+		return
+	}
+	if meta, ok := m["metadata"].(map[string]interface{}); ok {
+		if v := meta["resourceVersion"]; v == nil || fmt.Sprint(v) == e.fileVersion {
+			meta["resourceVersion"] = e.resourceVersion
+		} else {
+			meta["resourceVersion"] = fmt.Sprint(v)
+		}
+	}
+	body, err := json.Marshal(m)
+	if err != nil {
+		e.reject(edited, err)
+		return
+	}
+	result, err := be.mutateObject(replaceMutation(e.namespace, e.res, e.name, string(body)), true)
+	if err != nil {
+		e.reject(edited, e.conflicted(be, err))
+		return
+	}
+	e.manifest, e.body = edited, string(body)
+	e.preview = unifiedDiff(e.original, previewYaml(result), previewDiffContext)
+}
+
+// put the prepared manifest, it is rejected if the item was changed since the dry run
+func (e *editType) put(be *backendType) {
+	if _, err := be.mutateObject(replaceMutation(e.namespace, e.res, e.name, e.body), false); err != nil {
+		e.reject(e.manifest, e.conflicted(be, err))
+	}
+}
+
+// conflicted fetches the live item again, when the put was rejected because the item was changed in the meantime. The error for the user is returned
+func (e *editType) conflicted(be *backendType, err error) error {
+	mess := apiErrorMessage(err)
+	if !strings.Contains(err.Error(), "HTTP-Status: 409") {
+		return errors.New(mess)
+	}
+	if ferr := e.fetch(be); ferr != nil {
+		errorlog.Printf("can't fetch %s '%s' after conflict: %v", e.res.Name, e.name, ferr)
+		return errors.New(mess)
+	}
+	return fmt.Errorf("%s\nthe item was fetched again, your changes are put with its current version %s", mess, e.resourceVersion)
+}
+
+// summary the applied changes, added lines green and removed ones red
+func (e *editType) summary() string {
+	lines := []string{fmt.Sprintf("Edited %s '%s'", e.res.Name, bulkTargetType{namespace: e.namespace, name: e.name})}
+	for i, l := range e.diff {
+		if i == editSummaryMaxLines {
+			lines = append(lines, fmt.Sprintf("  ... and %d more", len(e.diff)-i))
+			break
+		}
+		color := greenEmpInlineColor
+		if strings.HasPrefix(l, "-") {
+			color = redEmpInlineColor
+		}
+		lines = append(lines, colorizeText(l, 0, len(l), color))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// reject writes the error as comment on top of the edited manifest, so the changes aren't lost when it is edited again
+func (e *editType) reject(edited string, err error) {
+	e.err = err
+	errorlog.Printf("edit of %s '%s' rejected: %v", e.res.Name, e.name, err)
+	lines := []string{editHeader}
+	for _, l := range strings.Split(strings.TrimSpace(err.Error()), "\n") {
+		lines = append(lines, "# "+l)
+	}
+	e.rejected = strings.Join(lines, "\n") + "\n" + edited
+	if werr := ioutil.WriteFile(e.file, []byte(e.rejected), 0600); werr != nil {
+		errorlog.Printf("can't write rejected edit to %s: %v", e.file, werr)
+	}
+	if fi, serr := os.Stat(e.file); serr == nil {
+		e.rejectedAt = fi.ModTime()
+	}
+}
+
+func (e *editType) remove() {
+	os.Remove(e.file)
+}
+
+// apiErrorMessage the message of the status returned by the api server, the whole error if there is none
+func apiErrorMessage(err error) string {
+	mess := err.Error()
+	i := strings.Index(mess, "Response: \n")
+	if i < 0 {
+		return mess
+	}
+	var status map[string]interface{}
+	if json.Unmarshal([]byte(mess[i+len("Response: \n"):]), &status) != nil || status["message"] == nil {
+		return mess
+	}
+	return fmt.Sprintf("%v (%v)", status["message"], status["reason"])
+}
+
+// jsonCompatible converts the maps with interface keys of yaml into maps with string keys
+func jsonCompatible(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := map[string]interface{}{}
+		for k, val := range t {
+			m[fmt.Sprintf("%v", k)] = jsonCompatible(val)
+		}
+		return m
+	case []interface{}:
+		for i, val := range t {
+			t[i] = jsonCompatible(val)
+		}
+	}
+	return v
+}
+
+// diffLines lines removed from a with '-' and added in b with '+', nil if they are equal
+func diffLines(a, b string) []string {
+	script, ok := editScript(a, b)
+	if !ok {
+		return []string{"+ too many changed lines to diff"}
+	}
+	var diff []string
+	for _, l := range script {
+		if l[0] != ' ' {
+			diff = append(diff, l)
+		}
+	}
+	return diff
+}
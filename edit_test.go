@@ -0,0 +1,156 @@
+package kubexp
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_DiffLines(t *testing.T) {
+	require := require.New(t)
+	require.Nil(diffLines("a\nb\n", "a\nb"))
+	require.Equal([]string{"- b", "+ x", "+ d"}, diffLines("a\nb\nc", "a\nx\nc\nd\n"))
+	require.Equal([]string{"- a"}, diffLines("a\nb", "b"))
+}
+
+func Test_JsonCompatible(t *testing.T) {
+	require := require.New(t)
+	v := jsonCompatible(map[interface{}]interface{}{"a": []interface{}{map[interface{}]interface{}{1: "b"}}})
+	require.Equal(map[string]interface{}{"a": []interface{}{map[string]interface{}{"1": "b"}}}, v)
+}
+
+// newEditTestServer answers gets with the item of version *version and puts with *status
+func newEditTestServer(status *int, version *string, puts *[]string) *httptest.Server {
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			fmt.Fprintf(w, `{"kind":"ConfigMap","apiVersion":"v1","metadata":{"name":"cfg","namespace":"dev","resourceVersion":"%s","managedFields":[{"manager":"kubectl"}]},"data":{"a":"1"}}`, *version)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		*puts = append(*puts, fmt.Sprintf("%s %s?%s %s", r.Method, r.URL.Path, r.URL.RawQuery, body))
+		w.WriteHeader(*status)
+		if *status == http.StatusConflict {
+			fmt.Fprint(w, `{"kind":"Status","status":"Failure","message":"the object has been modified","reason":"Conflict","code":409}`)
+			return
+		}
+		w.Write(body)
+	}))
+}
+
+func Test_EditApply(t *testing.T) {
+	require := require.New(t)
+	puts, status, version := []string{}, http.StatusOK, "7"
+	server := newEditTestServer(&status, &version, &puts)
+	defer server.Close()
+	be := newTestWatchBackend(server)
+	configmaps := resourceType{Name: "configmaps", APIPrefix: "api/v1", Namespace: true}
+
+	e, err := newEdit(be, configmaps, "dev", "cfg")
+	require.Nil(err)
+	defer e.remove()
+	require.Equal("7", e.resourceVersion)
+	require.NotContains(e.original, "managedFields")
+
+	e.prepare(be)
+	require.Nil(e.err)
+	require.Nil(e.diff, "an unchanged manifest isn't applied")
+	require.Empty(puts)
+
+	edited := strings.Replace(e.original, `a: "1"`, `a: "2"`, 1)
+	require.Nil(ioutil.WriteFile(e.file, []byte(edited), 0600))
+	e.prepare(be)
+	require.Nil(e.err)
+	require.Contains(e.diff, `+   a: "2"`)
+	require.Len(puts, 1)
+	require.Contains(puts[0], `PUT /api/v1/namespaces/dev/configmaps/cfg?dryRun=All {"apiVersion":"v1","data":{"a":"2"}`)
+	require.Equal([]string{"@@ -1,6 +1,6 @@", " apiVersion: v1", " data:", `-  a: "1"`, `+  a: "2"`, " kind: ConfigMap", " metadata:", "   name: cfg"}, e.preview, "the preview shows the result of the dry run")
+
+	e.put(be)
+	require.Nil(e.err)
+	require.Len(puts, 2)
+	require.Contains(puts[1], `PUT /api/v1/namespaces/dev/configmaps/cfg? {"apiVersion":"v1","data":{"a":"2"}`)
+	require.Contains(puts[1], `"resourceVersion":"7"`, "the edit is put with the version it is based on")
+	require.Contains(e.summary(), "Edited configmaps 'dev/cfg'")
+}
+
+func Test_EditRejected(t *testing.T) {
+	require := require.New(t)
+	puts, status, version := []string{}, http.StatusConflict, "7"
+	server := newEditTestServer(&status, &version, &puts)
+	defer server.Close()
+	be := newTestWatchBackend(server)
+	configmaps := resourceType{Name: "configmaps", APIPrefix: "api/v1", Namespace: true}
+	conflict := "# the object has been modified (Conflict)\n# the item was fetched again, your changes are put with its current version "
+
+	e, err := newEdit(be, configmaps, "dev", "cfg")
+	require.Nil(err)
+	defer e.remove()
+	edited := strings.Replace(e.original, `a: "1"`, `a: "2"`, 1)
+	require.Nil(ioutil.WriteFile(e.file, []byte(edited), 0600))
+	version = "8"
+	e.prepare(be)
+	require.NotNil(e.err, "the dry run is rejected")
+	require.True(strings.HasPrefix(e.err.Error(), "the object has been modified (Conflict)\n"))
+	require.Equal("8", e.resourceVersion, "the live item is fetched again after a conflict")
+	require.Contains(e.original, `resourceVersion: "8"`)
+
+	data, err := ioutil.ReadFile(e.file)
+	require.Nil(err)
+	require.Equal(editHeader+"\n"+conflict+"8\n"+edited, string(data), "the changes are kept for the next edit")
+
+	// leaving the editor without saving discards the edit
+	e.prepare(be)
+	require.True(e.discarded)
+	require.Len(puts, 1)
+
+	// saving the unchanged manifest puts it again with the version of the live item
+	e.discarded = false
+	status = http.StatusOK
+	later := time.Now().Add(time.Minute)
+	require.Nil(os.Chtimes(e.file, later, later))
+	e.prepare(be)
+	require.Nil(e.err)
+	require.False(e.discarded)
+	require.Len(puts, 2)
+	require.Contains(puts[1], `"resourceVersion":"8"`, "the second attempt is put with the new version")
+	require.NotContains(puts[1], "rejected")
+	e.put(be)
+	require.Nil(e.err)
+	require.Len(puts, 3)
+	require.Contains(puts[2], `"resourceVersion":"8"`)
+
+	// the comment is removed, before the manifest is applied again
+	status = http.StatusConflict
+	e.prepare(be)
+	data, _ = ioutil.ReadFile(e.file)
+	require.Nil(ioutil.WriteFile(e.file, []byte(strings.Replace(string(data), `a: "2"`, "a: \"2\"\n  b: \"3\"", 1)), 0600))
+	e.prepare(be)
+	require.Len(puts, 5)
+	require.NotContains(puts[4], "rejected")
+	require.Contains(puts[4], `"data":{"a":"2","b":"3"}`)
+
+	// a version set by the user is kept
+	e.discarded = false
+	require.Nil(ioutil.WriteFile(e.file, []byte(strings.Replace(edited, `resourceVersion: "7"`, `resourceVersion: "5"`, 1)), 0600))
+	e.prepare(be)
+	require.Contains(puts[5], `"resourceVersion":"5"`)
+
+	// a change between the dry run and the put is rejected too
+	e.err, e.manifest, e.body = nil, edited, `{"kind":"ConfigMap"}`
+	e.put(be)
+	require.NotNil(e.err)
+	data, _ = ioutil.ReadFile(e.file)
+	require.Equal(editHeader+"\n"+conflict+"8\n"+edited, string(data))
+
+	require.Nil(ioutil.WriteFile(e.file, []byte("a: [\n"), 0600))
+	e.prepare(be)
+	require.Contains(e.err.Error(), "invalid yaml")
+	require.Len(puts, 7)
+}
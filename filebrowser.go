@@ -15,12 +15,18 @@ type fileBrowserType struct {
 	sourceSelection bool
 	currentDir      string
 	createFileParts func(filename string) []interface{}
+	// manifestSelection a manifest file to create resources from is selected
+	manifestSelection bool
 }
 
 func newLocalFileBrowser(sourceSelection bool, dir string) *fileBrowserType {
 	return &fileBrowserType{local: true, sourceSelection: sourceSelection, currentDir: dir, createFileParts: createLocalFileParts}
 }
 
+func newManifestFileBrowser(dir string) *fileBrowserType {
+	return &fileBrowserType{local: true, sourceSelection: true, manifestSelection: true, currentDir: dir, createFileParts: createLocalFileParts}
+}
+
 func newRemoteFileBrowser(sourceSelection bool, dir string) *fileBrowserType {
 	return &fileBrowserType{local: false, sourceSelection: sourceSelection, currentDir: dir, createFileParts: createRemoteFileParts}
 }
@@ -34,6 +40,9 @@ func (f *fileBrowserType) getContext() string {
 	if len(fp) > 20 {
 		fp = "..." + fp[len(fp)-20:]
 	}
+	if f.manifestSelection {
+		return fmt.Sprintf("Select manifest file, dir: %-20.20s", fp)
+	}
 	podName := selectedResourceItemName()
 	containerName := containerNames[selectedContainerIndex]
 
@@ -92,7 +101,11 @@ func createRemoteFileParts(file string) []interface{} {
 	podName := selectedResourceItemName()
 	ns := selectedResourceItemNamespace()
 	con := containerNames[selectedContainerIndex]
-	cmd := kubectl(backend.context.Name, "-n", ns, "exec", podName, "-c", con, "--", "ls", "-l", "-a", file)
+	cmd, err := backend.kubectl("exec", ns, podName, "-c", con, "--", "ls", "-l", "-a", file)
+	if err != nil {
+		showError(fmt.Sprintf("Can't list files of pod '%s'", podName), err)
+		return fileList
+	}
 	lsStr, errorStr, err := runCmd(cmd)
 	if err != nil {
 		fullmess := fmt.Sprintf("%v: %s", err, errorStr)
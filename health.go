@@ -0,0 +1,142 @@
+package kubexp
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/alitari/gocui"
+)
+
+const (
+	contextChecking    = "checking"
+	contextReachable   = "reachable"
+	contextAuthFailed  = "auth failed"
+	contextUnreachable = "unreachable"
+)
+
+type contextHealthType struct {
+	mutex     sync.Mutex
+	status    string
+	err       error
+	checkTime time.Time
+}
+
+func newContextHealth() *contextHealthType {
+	return &contextHealthType{status: contextChecking}
+}
+
+func (h *contextHealthType) set(status string, err error) {
+	if h == nil {
+		return
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.status = status
+	h.err = err
+	h.checkTime = time.Now()
+}
+
+func (h *contextHealthType) get() (string, error) {
+	if h == nil {
+		return contextChecking, nil
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.status, h.err
+}
+
+// authErrorType marks errors which occur while authorizing a request, before anything is sent to the api server
+type authErrorType struct {
+	error
+}
+
+// Status result of the last health check of the context
+func (c contextType) Status() string {
+	status, _ := c.health.get()
+	return status
+}
+
+func (c contextType) healthError() error {
+	_, err := c.health.get()
+	return err
+}
+
+// probe checks if the api server of the context is reachable with the credentials of the context
+func (c contextType) probe() error {
+	c.health.set(contextChecking, nil)
+	url := fmt.Sprintf("%s/api", c.Cluster.URL)
+	resp, err := newBackend(c).restExecutor(http.MethodGet, url, "", restCallTimeout, nil)
+	status := contextReachable
+	switch {
+	case err != nil:
+		status = contextUnreachable
+		if _, ok := err.(authErrorType); ok {
+			status = contextAuthFailed
+		}
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		status = contextAuthFailed
+		err = fmt.Errorf("%s: http status %s", url, resp.Status)
+	case resp.StatusCode != http.StatusOK:
+		status = contextUnreachable
+		err = fmt.Errorf("%s: http status %s", url, resp.Status)
+	}
+	if resp != nil {
+		resp.Body.Close()
+	}
+	c.health.set(status, err)
+	if err != nil {
+		warninglog.Printf("context '%s' is %s: %v", c.Name, status, err)
+	} else {
+		infolog.Printf("context '%s' is %s", c.Name, status)
+	}
+	return err
+}
+
+// probeContexts checks all contexts, but the one at index skip, concurrently
+func (c *configType) probeContexts(skip int) *sync.WaitGroup {
+	var probes sync.WaitGroup
+	for i, ct := range c.contexts {
+		if i == skip {
+			continue
+		}
+		probes.Add(1)
+		go func(ct contextType) {
+			defer probes.Done()
+			ct.probe()
+			refreshClusterList()
+		}(ct)
+	}
+	return &probes
+}
+
+// probeStartContext checks all contexts in the background, while the ui starts with the start context. When the start context
+// is not reachable, the first reachable context is loaded after all contexts are checked, unless another context was selected meanwhile
+func (c *configType) probeStartContext() {
+	start := c.contexts[c.currentContext]
+	probes := c.probeContexts(c.currentContext)
+	go func() {
+		infolog.Printf("try connecting context '%s', cluster: '%s' ...", start.Name, start.Cluster.Name)
+		err := start.probe()
+		refreshClusterList()
+		if err == nil {
+			return
+		}
+		probes.Wait()
+		for i, ct := range c.contexts {
+			if ct.Status() == contextReachable {
+				infolog.Printf("context '%s' is %s, starting with context '%s'", start.Name, start.Status(), ct.Name)
+				g.Update(func(gui *gocui.Gui) error {
+					if backend.context.Name == start.Name {
+						clusterList.widget.selectItem(i)
+						newContext()
+					}
+					return nil
+				})
+				return
+			}
+		}
+		showError(fmt.Sprintf("No reachable contexts in configfile '%s'", c.configFile), err)
+	}()
+}
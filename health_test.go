@@ -0,0 +1,45 @@
+package kubexp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ContextProbe(t *testing.T) {
+	require := require.New(t)
+	server := newTestAPIServer()
+	defer server.Close()
+	denying := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer denying.Close()
+
+	ctx := testContext(server, clusterType{insecureSkipTLSVerify: true})
+	ctx.health = newContextHealth()
+	require.Equal(contextChecking, ctx.Status())
+	require.Nil(ctx.probe())
+	require.Equal(contextReachable, ctx.Status())
+
+	ctx = testContext(denying, clusterType{insecureSkipTLSVerify: true})
+	ctx.health = newContextHealth()
+	require.NotNil(ctx.probe())
+	require.Equal(contextAuthFailed, ctx.Status())
+
+	ctx = testContext(server, clusterType{insecureSkipTLSVerify: true})
+	ctx.health = newContextHealth()
+	ctx.user = userType{Name: "broken", tokenFile: "/nonexisting/token"}
+	ctx.user.auth = selectAuthStrategy(ctx.user)
+	require.NotNil(ctx.probe())
+	require.Equal(contextAuthFailed, ctx.Status())
+
+	unreachable := httptest.NewTLSServer(testAPIHandler)
+	ctx = testContext(unreachable, clusterType{insecureSkipTLSVerify: true})
+	unreachable.Close()
+	ctx.health = newContextHealth()
+	require.NotNil(ctx.probe())
+	require.Equal(contextUnreachable, ctx.Status())
+	require.NotNil(ctx.healthError())
+}
@@ -33,8 +33,11 @@ func newPortforwardProxy(namespace, podName string, ports portMapping) *portforw
 }
 
 func (p *portforwardProxy) execute() error {
+	cmd, err := backend.kubectl("port-forward", p.namespace, p.pod, fmt.Sprintf("%v:%v", p.mapping.destPort, p.mapping.containerPort.port))
+	if err != nil {
+		return err
+	}
 	go func() {
-		cmd := kubectl(backend.context.Name, "port-forward", "-n", p.namespace, p.pod, fmt.Sprintf("%v:%v", p.mapping.destPort, p.mapping.containerPort.port))
 		cmd.Output()
 	}()
 	return nil
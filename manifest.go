@@ -0,0 +1,192 @@
+package kubexp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// applyQuery server side apply of kubexp
+const applyQuery = "fieldManager=kubexp"
+
+// applyPatchType patch type of server side apply
+const applyPatchType = "application/apply-patch+yaml"
+
+// manifestObjectType object of a manifest file with the resource it is created as, err is set if it can't be created
+type manifestObjectType struct {
+	obj             map[string]interface{}
+	kind            string
+	res             resourceType
+	namespace, name string
+	err             error
+}
+
+// manifestResultType outcome of creating a manifest object, configured if the object existed already
+type manifestResultType struct {
+	object     manifestObjectType
+	configured bool
+	err        error
+}
+
+func (o manifestObjectType) String() string {
+	return fmt.Sprintf("%s %s", o.kind, bulkTargetType{namespace: o.namespace, name: o.name})
+}
+
+// parseManifest the objects of a json or a multi document yaml manifest, the items of lists are taken as objects
+func parseManifest(data []byte) ([]map[string]interface{}, error) {
+	objs := []map[string]interface{}{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for i := 1; ; i++ {
+		var doc interface{}
+		err := dec.Decode(&doc)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("document %d: %v", i, err)
+		}
+		if doc == nil {
+			continue
+		}
+		obj, ok := jsonCompatible(doc).(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("document %d is not an object", i)
+		}
+		if kind, _ := obj["kind"].(string); strings.HasSuffix(kind, "List") {
+			items, _ := obj["items"].([]interface{})
+			for _, it := range items {
+				if item, ok := it.(map[string]interface{}); ok {
+					objs = append(objs, item)
+				}
+			}
+			continue
+		}
+		objs = append(objs, obj)
+	}
+	if len(objs) == 0 {
+		return nil, fmt.Errorf("no objects found")
+	}
+	return objs, nil
+}
+
+// resolveManifest maps the objects to the served resources, namespaced objects without namespace are created in ns
+func (c *configType) resolveManifest(objs []map[string]interface{}, ns string) []manifestObjectType {
+	ret := make([]manifestObjectType, len(objs))
+	for i, obj := range objs {
+		apiVersion, _ := obj["apiVersion"].(string)
+		kind, _ := obj["kind"].(string)
+		meta, _ := obj["metadata"].(map[string]interface{})
+		if meta == nil {
+			meta = map[string]interface{}{}
+			obj["metadata"] = meta
+		}
+		name, _ := meta["name"].(string)
+		generateName, _ := meta["generateName"].(string)
+		o := manifestObjectType{obj: obj, kind: kind, name: name}
+		if o.name == "" {
+			o.name = generateName
+		}
+		res, found := c.resourceOfKind(apiVersion, kind)
+		switch {
+		case apiVersion == "" || kind == "":
+			o.err = fmt.Errorf("apiVersion and kind are required")
+		case !found:
+			o.err = fmt.Errorf("kind '%s' of '%s' is not served by the api server", kind, apiVersion)
+		case o.name == "":
+			o.err = fmt.Errorf("name is required")
+		}
+		o.res = res
+		if res.Namespace {
+			o.namespace, _ = meta["namespace"].(string)
+			if o.namespace == "" {
+				o.namespace = ns
+				meta["namespace"] = ns
+			}
+		}
+		ret[i] = o
+	}
+	return ret
+}
+
+// createObject creates the object, an existing one is configured with server side apply. With dryRun the result is returned, but not persisted
+func createObject(be *backendType, o manifestObjectType, dryRun bool) (manifestResultType, map[string]interface{}) {
+	if o.err != nil {
+		return manifestResultType{object: o, err: o.err}, nil
+	}
+	body, err := json.Marshal(o.obj)
+	if err != nil {
+		return manifestResultType{object: o, err: err}, nil
+	}
+	r := manifestResultType{object: o}
+	result, err := be.mutateObject(createMutation(o.namespace, o.res, string(body)), dryRun)
+	// objects with generateName get a new name each time
+	meta, _ := o.obj["metadata"].(map[string]interface{})
+	if err != nil && strings.Contains(err.Error(), "HTTP-Status: 409") && meta["name"] != nil {
+		r.configured = true
+		result, err = be.mutateObject(applyMutation(o.namespace, o.res, o.name, string(body)), dryRun)
+	}
+	if err != nil {
+		errorlog.Printf("can't create %s: %v", o, err)
+		r.err = errors.New(apiErrorMessage(err))
+	}
+	return r, result
+}
+
+// previewObject the object as it would be created, the diff to the live object if it would be configured
+func previewObject(be *backendType, o manifestObjectType) []string {
+	lines := []string{colorizeText(o.String(), 0, len(o.String()), whiteEmpInlineColor)}
+	r, result := createObject(be, o, true)
+	if r.err != nil {
+		return append(lines, previewError("dry run failed", r.err))
+	}
+	var live map[string]interface{}
+	if r.configured {
+		var err error
+		if live, err = be.get(o.namespace, o.res, o.name); err != nil {
+			return append(lines, previewError("can't get live object", err))
+		}
+	}
+	return append(lines, previewDiff(unifiedDiff(previewYaml(live), previewYaml(result), previewDiffContext))...)
+}
+
+// manifestConfirmMessage lists the objects of the manifest
+func manifestConfirmMessage(file string, objs []manifestObjectType) string {
+	if len(objs) == 1 {
+		return fmt.Sprintf("Create %s of '%s' ?", objs[0], file)
+	}
+	lines := []string{fmt.Sprintf("Create %d objects of '%s' ?", len(objs), file)}
+	for i, o := range objs {
+		if i == bulkConfirmMaxItems {
+			lines = append(lines, fmt.Sprintf("  ... and %d more", len(objs)-i))
+			break
+		}
+		lines = append(lines, "  "+o.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// manifestSummary counts of the created, configured and failed objects, followed by the result of each object, failures first
+func manifestSummary(file string, results []manifestResultType) string {
+	failed, succeeded := []string{}, []string{}
+	created, configured := 0, 0
+	for _, r := range results {
+		switch {
+		case r.err != nil:
+			mess := strings.SplitN(strings.TrimSpace(r.err.Error()), "\n", 2)[0]
+			failed = append(failed, colorizeText("  failed     ", 0, 13, redEmpInlineColor)+fmt.Sprintf("%s: %s", r.object, mess))
+		case r.configured:
+			configured++
+			succeeded = append(succeeded, colorizeText("  configured ", 0, 13, greenEmpInlineColor)+r.object.String())
+		default:
+			created++
+			succeeded = append(succeeded, colorizeText("  created    ", 0, 13, greenEmpInlineColor)+r.object.String())
+		}
+	}
+	lines := []string{fmt.Sprintf("Create '%s': %d created, %d configured, %d failed", file, created, configured, len(failed))}
+	return strings.Join(append(append(lines, failed...), succeeded...), "\n")
+}
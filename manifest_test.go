@@ -0,0 +1,122 @@
+package kubexp
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ParseManifest(t *testing.T) {
+	require := require.New(t)
+	objs, err := parseManifest([]byte(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cfg
+data:
+  replicas: 3
+---
+# only a comment
+---
+{"apiVersion":"v1","kind":"List","items":[{"apiVersion":"batch/v1","kind":"Job","metadata":{"name":"debug"}},{"apiVersion":"v1","kind":"Namespace","metadata":{"name":"dev"}}]}
+`))
+	require.Nil(err)
+	require.Len(objs, 3)
+	require.Equal("cfg", resItemName(objs[0]))
+	require.Equal(map[string]interface{}{"replicas": 3}, objs[0]["data"])
+	require.Equal("Job", objs[1]["kind"])
+	require.Equal("dev", resItemName(objs[2]))
+
+	for _, invalid := range []string{"", "---\n", "- a\n- b", "a: [\n"} {
+		_, err = parseManifest([]byte(invalid))
+		require.NotNil(err, invalid)
+	}
+}
+
+func Test_ResolveManifest(t *testing.T) {
+	require := require.New(t)
+	c := &configType{apiKinds: map[string]apiResourceType{
+		"/ConfigMap": {groupVersion: "v1", name: "configmaps", kind: "ConfigMap", namespaced: true},
+		"/Namespace": {groupVersion: "v1", name: "namespaces", kind: "Namespace"},
+		"batch/Job":  {group: "batch", groupVersion: "batch/v1", name: "jobs", kind: "Job", namespaced: true},
+	}}
+	objs := c.resolveManifest([]map[string]interface{}{
+		unmarshall(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"cfg"}}`),
+		unmarshall(`{"apiVersion":"batch/v1beta1","kind":"Job","metadata":{"generateName":"debug-","namespace":"test"}}`),
+		unmarshall(`{"apiVersion":"v1","kind":"Namespace","metadata":{"name":"dev"}}`),
+		unmarshall(`{"apiVersion":"example.com/v1","kind":"Widget","metadata":{"name":"w"}}`),
+		unmarshall(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{}}`),
+	}, "dev")
+	require.Len(objs, 5)
+	require.Nil(objs[0].err)
+	require.Equal(resourceType{Name: "configmaps", APIPrefix: "api/v1", Namespace: true}, objs[0].res)
+	require.Equal("dev", objs[0].namespace)
+	require.Equal("dev", resItemNamespace(objs[0].obj), "the namespace is set in the object")
+	require.Equal("ConfigMap dev/cfg", objs[0].String())
+	require.Nil(objs[1].err)
+	require.Equal("apis/batch/v1beta1", objs[1].res.APIPrefix, "the version of the manifest is used")
+	require.Equal("test", objs[1].namespace)
+	require.Equal("debug-", objs[1].name)
+	require.Nil(objs[2].err)
+	require.Equal("", objs[2].namespace)
+	require.Contains(objs[3].err.Error(), "not served")
+	require.Contains(objs[4].err.Error(), "name is required")
+}
+
+func Test_CreateObjects(t *testing.T) {
+	require := require.New(t)
+	requests := []string{}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		requests = append(requests, fmt.Sprintf("%s %s?%s %s %s", r.Method, r.URL.Path, r.URL.RawQuery, r.Header.Get("Content-Type"), body))
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v1/namespaces/dev/configmaps":
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, `{"kind":"ConfigMap"}`)
+		case r.Method == http.MethodPost:
+			w.WriteHeader(http.StatusConflict)
+			fmt.Fprint(w, `{"kind":"Status","status":"Failure","message":"jobs.batch \"debug\" already exists","reason":"AlreadyExists","code":409}`)
+		case r.URL.Path == "/apis/batch/v1/namespaces/dev/jobs/debug":
+			fmt.Fprint(w, `{"kind":"Job"}`)
+		default:
+			w.WriteHeader(http.StatusConflict)
+			fmt.Fprint(w, `{"kind":"Status","status":"Failure","message":"Apply failed with 1 conflict","reason":"Conflict","code":409}`)
+		}
+	}))
+	defer server.Close()
+	be := newTestWatchBackend(server)
+	configmaps := resourceType{Name: "configmaps", APIPrefix: "api/v1", Namespace: true}
+	jobs := resourceType{Name: "jobs", APIPrefix: "apis/batch/v1", Namespace: true}
+	objs := []manifestObjectType{
+		{obj: unmarshall(`{"kind":"ConfigMap","metadata":{"name":"cfg","namespace":"dev"}}`), kind: "ConfigMap", res: configmaps, namespace: "dev", name: "cfg"},
+		{obj: unmarshall(`{"kind":"Job","metadata":{"name":"debug","namespace":"dev"}}`), kind: "Job", res: jobs, namespace: "dev", name: "debug"},
+		{obj: unmarshall(`{"kind":"Job","metadata":{"name":"other","namespace":"dev"}}`), kind: "Job", res: jobs, namespace: "dev", name: "other"},
+		{kind: "Widget", name: "w", err: fmt.Errorf("kind 'Widget' of 'example.com/v1' is not served by the api server")},
+	}
+	results := []manifestResultType{}
+	for _, o := range objs {
+		r, _ := createObject(be, o, false)
+		results = append(results, r)
+	}
+	require.Nil(results[0].err)
+	require.False(results[0].configured)
+	require.Nil(results[1].err)
+	require.True(results[1].configured)
+	require.Equal("Apply failed with 1 conflict (Conflict)", results[2].err.Error())
+	require.NotNil(results[3].err)
+
+	require.Len(requests, 5, "objects which can't be resolved are not sent")
+	require.Equal(`POST /api/v1/namespaces/dev/configmaps? application/json {"kind":"ConfigMap","metadata":{"name":"cfg","namespace":"dev"}}`, requests[0])
+	require.Equal(`PATCH /apis/batch/v1/namespaces/dev/jobs/debug?fieldManager=kubexp application/apply-patch+yaml {"kind":"Job","metadata":{"name":"debug","namespace":"dev"}}`, requests[2])
+
+	summary := inlineColorRegexp.ReplaceAllString(manifestSummary("debug.yaml", results), "")
+	require.Equal(`Create 'debug.yaml': 1 created, 1 configured, 2 failed
+  failed     Job dev/other: Apply failed with 1 conflict (Conflict)
+  failed     Widget w: kind 'Widget' of 'example.com/v1' is not served by the api server
+  created    ConfigMap dev/cfg
+  configured Job dev/debug`, summary)
+}
@@ -0,0 +1,127 @@
+package kubexp
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// policyType what kubexp may change in the cluster of a context
+type policyType string
+
+const (
+	// readWritePolicy all actions are allowed, contexts without policy have it
+	readWritePolicy policyType = "readwrite"
+	// readOnlyPolicy mutations of the api server and kubectl exec, cp and port-forward are rejected
+	readOnlyPolicy policyType = "readonly"
+	// confirmTwicePolicy mutations are confirmed a second time, after their preview
+	confirmTwicePolicy policyType = "confirm-twice"
+)
+
+var policyFile *string
+
+// readOnlyMode the -readonly flag, every context is read-only
+var readOnlyMode bool
+
+// policyRuleType policy of the contexts, whose name matches the pattern
+type policyRuleType struct {
+	pattern string
+	policy  policyType
+}
+
+// parsePolicies the rules of the 'policies' map of a kubexp config, in the order of the file
+func parsePolicies(data []byte) ([]policyRuleType, error) {
+	var c struct {
+		Policies yaml.MapSlice `yaml:"policies"`
+	}
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	rules := []policyRuleType{}
+	for _, p := range c.Policies {
+		pattern := fmt.Sprint(p.Key)
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid context pattern '%s': %v", pattern, err)
+		}
+		policy := policyType(fmt.Sprint(p.Value))
+		switch policy {
+		case readWritePolicy, readOnlyPolicy, confirmTwicePolicy:
+		default:
+			return nil, fmt.Errorf("invalid policy '%s' of '%s', values: '%s','%s','%s'", policy, pattern, readWritePolicy, readOnlyPolicy, confirmTwicePolicy)
+		}
+		rules = append(rules, policyRuleType{pattern: pattern, policy: policy})
+	}
+	return rules, nil
+}
+
+// loadPolicies the rules of the kubexp config file, there are none if it doesn't exist
+func loadPolicies(file string) []policyRuleType {
+	if file == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			fatalStderrlog.Fatalf("Can't read file %s: %v", file, err.Error())
+		}
+		return nil
+	}
+	rules, err := parsePolicies(data)
+	if err != nil {
+		fatalStderrlog.Fatalf("Didn't understand the policies in file %s: %v", file, err.Error())
+	}
+	infolog.Printf("read %d context policies from %s", len(rules), file)
+	return rules
+}
+
+// contextPolicy the policy of the first rule matching the context name, with the -readonly flag every context is read-only
+func contextPolicy(rules []policyRuleType, contextName string) policyType {
+	if readOnlyMode {
+		return readOnlyPolicy
+	}
+	for _, r := range rules {
+		if matched, _ := path.Match(r.pattern, contextName); matched {
+			return r.policy
+		}
+	}
+	return readWritePolicy
+}
+
+// readOnly nothing may be changed in the cluster of the context
+func (c contextType) readOnly() bool {
+	return c.policy == readOnlyPolicy
+}
+
+// checkPolicy rejects the action, if the policy of the context doesn't allow it. Every request and kubectl call of the backend is checked
+func (b *backendType) checkPolicy(action string) error {
+	if b.context.readOnly() {
+		warninglog.Printf("rejected %s in read-only context '%s'", action, b.context.Name)
+		return fmt.Errorf("context '%s' is read-only, %s is not allowed", b.context.Name, action)
+	}
+	return nil
+}
+
+// checkRequest rejects requests other than GET, if the policy of the context doesn't allow them. The TokenRequest of the service account authentication only reads credentials and is allowed
+func (b *backendType) checkRequest(httpMethod, url string) error {
+	if httpMethod == http.MethodGet {
+		return nil
+	}
+	resource := strings.SplitN(strings.TrimPrefix(strings.TrimPrefix(url, b.context.Cluster.URL.String()), "/"), "?", 2)[0]
+	if matched, _ := path.Match(tokenRequestPath, resource); matched && httpMethod == http.MethodPost {
+		return nil
+	}
+	return b.checkPolicy(fmt.Sprintf("%s %s", httpMethod, resource))
+}
+
+// tokenRequestPath path of the TokenRequest of a service account
+const tokenRequestPath = "api/v1/namespaces/*/serviceaccounts/*/token"
+
+func defaultPolicyFile() string {
+	return filepath.Join(homeDir(), ".kube", "kubexp.yaml")
+}
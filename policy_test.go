@@ -0,0 +1,81 @@
+package kubexp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ParsePolicies(t *testing.T) {
+	require := require.New(t)
+	rules, err := parsePolicies([]byte(`
+policies:
+  prod-*: readonly
+  staging: confirm-twice
+  "*": readwrite
+`))
+	require.Nil(err)
+	require.Equal([]policyRuleType{{"prod-*", readOnlyPolicy}, {"staging", confirmTwicePolicy}, {"*", readWritePolicy}}, rules)
+
+	rules, err = parsePolicies([]byte("other: true\n"))
+	require.Nil(err)
+	require.Empty(rules)
+
+	_, err = parsePolicies([]byte("policies:\n  prod: read-only\n"))
+	require.Contains(err.Error(), "invalid policy 'read-only' of 'prod'")
+	_, err = parsePolicies([]byte("policies:\n  \"prod-[\": readonly\n"))
+	require.Contains(err.Error(), "invalid context pattern 'prod-['")
+}
+
+func Test_ContextPolicy(t *testing.T) {
+	require := require.New(t)
+	rules := []policyRuleType{{"prod-*", readOnlyPolicy}, {"prod-eu", confirmTwicePolicy}, {"staging", confirmTwicePolicy}}
+	require.Equal(readOnlyPolicy, contextPolicy(rules, "prod-eu"), "the first matching rule wins")
+	require.Equal(confirmTwicePolicy, contextPolicy(rules, "staging"))
+	require.Equal(readWritePolicy, contextPolicy(rules, "dev"))
+	require.Equal(readWritePolicy, contextPolicy(nil, "dev"))
+
+	readOnlyMode = true
+	defer func() { readOnlyMode = false }()
+	require.Equal(readOnlyPolicy, contextPolicy(rules, "dev"))
+}
+
+func Test_ReadOnlyContext(t *testing.T) {
+	require := require.New(t)
+	requests := 0
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(`{"kind":"Status","status":"Success"}`))
+	}))
+	defer server.Close()
+	be := newTestWatchBackend(server)
+	deployments := resourceType{Name: "deployments", APIPrefix: "apis/apps/v1", Namespace: true}
+
+	_, err := be.mutate(deleteMutation("dev", deployments, "web", false), false)
+	require.Nil(err)
+	require.Equal(1, requests)
+	cmd, err := be.kubectl("exec", "dev", "web", "--", "ls")
+	require.Nil(err)
+	require.Equal([]string{"kubectl", "--context=" + be.context.Name, "--request-timeout=0s", "-n", "dev", "exec", "web", "--", "ls"}, cmd.Args[len(cmd.Args)-9:])
+
+	be.context.policy = readOnlyPolicy
+	_, err = be.mutate(deleteMutation("dev", deployments, "web", false), true)
+	require.Equal("context '"+be.context.Name+"' is read-only, DELETE apis/apps/v1/namespaces/dev/deployments/web is not allowed", err.Error())
+	_, err = be.mutateObject(scaleMutation("dev", deployments, "web", nil, 1), false)
+	require.NotNil(err)
+	require.Equal(1, requests, "no mutation is sent, not even a dry run")
+	_, err = be.restCall(http.MethodDelete, "api/v1", "pods/web-1", "dev", "")
+	require.Equal("context '"+be.context.Name+"' is read-only, DELETE api/v1/namespaces/dev/pods/web-1 is not allowed", err.Error())
+	_, err = be.restCallNoNs(http.MethodPost, "api/v1", "namespaces", `{"metadata":{"name":"new"}}`)
+	require.NotNil(err)
+	require.Equal(1, requests, "raw requests changing the cluster are rejected as well")
+	_, err = be.restCall(http.MethodGet, "api/v1", "pods/web-1", "dev", "")
+	require.Nil(err)
+	_, err = be.restCall(http.MethodPost, "api/v1", "serviceaccounts/viewer/token", "dev", "{}")
+	require.Nil(err)
+	require.Equal(3, requests, "reading and requesting a service account token are allowed")
+	_, err = be.kubectl("port-forward", "dev", "web", "8080:80")
+	require.Equal("context '"+be.context.Name+"' is read-only, kubectl port-forward is not allowed", err.Error())
+}
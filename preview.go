@@ -0,0 +1,426 @@
+package kubexp
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// dryRunQuery the api server validates the request and returns its result, without persisting it
+const dryRunQuery = "dryRun=All"
+
+// maximum of targets of a bulk action, whose previews are shown. All targets run as dry run
+var bulkPreviewMaxItems = 5
+
+// lines of context around the changes of a preview diff
+var previewDiffContext = 3
+
+// dependentType resource item owned by another item
+type dependentType struct {
+	resName string
+	item    interface{}
+}
+
+func (d dependentType) String() string {
+	return fmt.Sprintf("%s %s", d.resName, bulkTargetType{namespace: resItemNamespace(d.item), name: resItemName(d.item)})
+}
+
+// maximum of the product of the numbers of changed lines of a diff, larger changes are too large to diff
+var previewDiffMaxCells = 1000000
+
+// editScript the lines of b, the lines only in a with '- ', the ones only in b with '+ ' and the common ones with '  '.
+// False if the lines between the common first and last lines are too many to compare
+func editScript(a, b string) ([]string, bool) {
+	al, bl := strings.Split(strings.TrimRight(a, "\n"), "\n"), strings.Split(strings.TrimRight(b, "\n"), "\n")
+	if a == "" {
+		al = nil
+	}
+	if b == "" {
+		bl = nil
+	}
+	prefix := 0
+	for prefix < len(al) && prefix < len(bl) && al[prefix] == bl[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(al)-prefix && suffix < len(bl)-prefix && al[len(al)-1-suffix] == bl[len(bl)-1-suffix] {
+		suffix++
+	}
+	var script []string
+	for _, l := range al[:prefix] {
+		script = append(script, "  "+l)
+	}
+	am, bm := al[prefix:len(al)-suffix], bl[prefix:len(bl)-suffix]
+	if (len(am)+1)*(len(bm)+1) > previewDiffMaxCells {
+		return nil, false
+	}
+	// lcs[i][j] length of the longest common subsequence of am[i:] and bm[j:]
+	lcs := make([][]int, len(am)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(bm)+1)
+	}
+	for i := len(am) - 1; i >= 0; i-- {
+		for j := len(bm) - 1; j >= 0; j-- {
+			if am[i] == bm[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(am) || j < len(bm) {
+		switch {
+		case i < len(am) && j < len(bm) && am[i] == bm[j]:
+			script = append(script, "  "+am[i])
+			i++
+			j++
+		case i < len(am) && (j == len(bm) || lcs[i+1][j] >= lcs[i][j+1]):
+			script = append(script, "- "+am[i])
+			i++
+		default:
+			script = append(script, "+ "+bm[j])
+			j++
+		}
+	}
+	for _, l := range al[len(al)-suffix:] {
+		script = append(script, "  "+l)
+	}
+	return script, true
+}
+
+// unifiedDiff the changes from a to b in hunks with context lines, like 'diff -u'. Nil if they are equal, a single line if they are too large to diff
+func unifiedDiff(a, b string, context int) []string {
+	script, ok := editScript(a, b)
+	if !ok {
+		return []string{"@@ too many changed lines to diff @@"}
+	}
+	include := make([]bool, len(script))
+	for c, l := range script {
+		if l[0] != ' ' {
+			for i := max(c-context, 0); i <= min(c+context, len(script)-1); i++ {
+				include[i] = true
+			}
+		}
+	}
+	var diff []string
+	// numbers of the next lines of a and b
+	aLine, bLine := 1, 1
+	for i := 0; i < len(script); {
+		if !include[i] {
+			aLine++
+			bLine++
+			i++
+			continue
+		}
+		aStart, bStart := aLine, bLine
+		hunk := []string{}
+		for ; i < len(script) && include[i]; i++ {
+			l := script[i]
+			hunk = append(hunk, l[:1]+l[2:])
+			if l[0] != '+' {
+				aLine++
+			}
+			if l[0] != '-' {
+				bLine++
+			}
+		}
+		// an empty range starts at the line before it, like in 'diff -u'
+		aCount, bCount := aLine-aStart, bLine-bStart
+		if aCount == 0 {
+			aStart--
+		}
+		if bCount == 0 {
+			bStart--
+		}
+		diff = append(diff, fmt.Sprintf("@@ -%d,%d +%d,%d @@", aStart, aCount, bStart, bCount))
+		diff = append(diff, hunk...)
+	}
+	return diff
+}
+
+// previewYaml the object as yaml without the managed fields
+func previewYaml(obj map[string]interface{}) string {
+	if obj == nil {
+		return ""
+	}
+	c := map[string]interface{}{}
+	for k, v := range obj {
+		c[k] = v
+	}
+	if meta, ok := obj["metadata"].(map[string]interface{}); ok {
+		m := map[string]interface{}{}
+		for k, v := range meta {
+			if k != "managedFields" {
+				m[k] = v
+			}
+		}
+		c["metadata"] = m
+	}
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		errorlog.Printf("can't marshal preview: %v", err)
+	}
+	return string(data)
+}
+
+// previewMutation the diff of the live item and the result of the mutation in a dry run, the live item is nil when it is created
+func (b *backendType) previewMutation(m mutationType, live map[string]interface{}) ([]string, error) {
+	result, err := b.mutateObject(m, true)
+	if err != nil {
+		return nil, err
+	}
+	return unifiedDiff(previewYaml(live), previewYaml(result), previewDiffContext), nil
+}
+
+// ownedResourceType resource whose items may be owned by the items of another kind, with the kind of its items
+type ownedResourceType struct {
+	key, kind string
+}
+
+// ownedResources the resources whose items may be owned by the items of a kind, e.g. the replica sets of a deployment. Only these are listed for the dependents of an item
+var ownedResources = map[string][]ownedResourceType{
+	"Deployment":            {{"apps/replicasets", "ReplicaSet"}},
+	"ReplicaSet":            {{"pods", "Pod"}},
+	"StatefulSet":           {{"pods", "Pod"}, {"apps/controllerrevisions", "ControllerRevision"}},
+	"DaemonSet":             {{"pods", "Pod"}, {"apps/controllerrevisions", "ControllerRevision"}},
+	"ReplicationController": {{"pods", "Pod"}},
+	"CronJob":               {{"batch/jobs", "Job"}},
+	"Job":                   {{"pods", "Pod"}},
+	"Service":               {{"endpoints", "Endpoints"}, {"discovery.k8s.io/endpointslices", "EndpointSlice"}},
+	"Pod":                   {},
+	"ControllerRevision":    {},
+	"Endpoints":             {},
+	"EndpointSlice":         {},
+}
+
+// maximum of items listed of a resource for the dependents
+var dependentsMaxItems = 2000
+
+// ownerIndexType items by the uids of their owners. The items of the watched resources are taken from the store, the others are listed in the namespace of the owner
+type ownerIndexType struct {
+	resources []resourceType
+	owned     map[string][]dependentType
+	// loaded resources whose items are indexed, by namespace/key and by key if the items of all namespaces are
+	loaded map[string]bool
+	// unchecked resources, which can't be listed completely, with the reason
+	unchecked map[string]string
+	// watchedOnly kinds with unknown dependents, only the watched resources are checked for them
+	watchedOnly map[string]bool
+}
+
+func newOwnerIndex(resources []resourceType) *ownerIndexType {
+	return &ownerIndexType{resources: resources, owned: map[string][]dependentType{}, loaded: map[string]bool{}, unchecked: map[string]string{}, watchedOnly: map[string]bool{}}
+}
+
+func (x *ownerIndexType) add(resName string, items []interface{}) {
+	for _, ri := range items {
+		m, _ := ri.(map[string]interface{})
+		meta, _ := m["metadata"].(map[string]interface{})
+		refs, _ := meta["ownerReferences"].([]interface{})
+		for _, ref := range refs {
+			r, _ := ref.(map[string]interface{})
+			if uid, _ := r["uid"].(string); uid != "" {
+				x.owned[uid] = append(x.owned[uid], dependentType{resName: resName, item: ri})
+			}
+		}
+	}
+}
+
+// load indexes the resources, whose items may be owned by the items of the kind, in namespace ns. Dependents of a namespaced item are in its namespace,
+// the ones of a cluster scoped item can be anywhere. For kinds with unknown dependents, e.g. custom resources, only the watched resources are indexed
+func (x *ownerIndexType) load(b *backendType, ns, kind string) {
+	owned, known := ownedResources[kind]
+	if !known {
+		x.watchedOnly[kind] = true
+		for _, res := range x.resources {
+			if b.watchState(res.key()) == watchOnline {
+				x.loadResource(b, res, ns)
+			}
+		}
+		return
+	}
+	for _, o := range owned {
+		for _, res := range x.resources {
+			if res.key() == o.key {
+				x.loadResource(b, res, ns)
+				x.load(b, ns, o.kind)
+			}
+		}
+	}
+}
+
+// loadResource indexes the items of the resource in namespace ns, the items of a watched resource are taken from the store. Others are listed in chunks up to dependentsMaxItems
+func (x *ownerIndexType) loadResource(b *backendType, res resourceType, ns string) {
+	if !res.Namespace {
+		ns = ""
+	}
+	if x.loaded[res.key()] || x.loaded[storeKey(ns, res.key())] {
+		return
+	}
+	if b.watchState(res.key()) == watchOnline {
+		x.loaded[res.key()] = true
+		x.add(res.Name, b.store.list(res.key()))
+		return
+	}
+	if ns == "" {
+		x.loaded[res.key()] = true
+	} else {
+		x.loaded[storeKey(ns, res.key())] = true
+	}
+	w := newResourceWatch(res, ns)
+	items := []interface{}{}
+	for token := ""; ; {
+		page, err := b.listPage(w, token)
+		if err != nil {
+			warninglog.Printf("can't list %s for dependents: %v", w, err)
+			x.unchecked[res.Name] = "can't list"
+			break
+		}
+		items = append(items, page.objects()...)
+		if token = page.Metadata.Continue; token == "" {
+			break
+		}
+		if len(items) >= dependentsMaxItems {
+			x.unchecked[res.Name] = fmt.Sprintf("more than %d items", dependentsMaxItems)
+			break
+		}
+	}
+	x.add(res.Name, items)
+}
+
+// dependents the items, which are owned by the item directly or indirectly. They are garbage collected, when it is deleted
+func (x *ownerIndexType) dependents(b *backendType, item interface{}) []string {
+	// resItemNamespace is "<no value>" for cluster scoped items
+	x.load(b, configValue(item, "{{ .metadata.namespace }}"), configValue(item, "{{ .kind }}"))
+	lines := []string{}
+	seen := map[string]bool{}
+	var walk func(uid string, depth int)
+	walk = func(uid string, depth int) {
+		deps := x.owned[uid]
+		sort.Slice(deps, func(i, j int) bool { return deps[i].String() < deps[j].String() })
+		for _, d := range deps {
+			duid := resItemUID(d.item)
+			if seen[duid] {
+				continue
+			}
+			seen[duid] = true
+			lines = append(lines, strings.Repeat("  ", depth)+d.String())
+			walk(duid, depth+1)
+		}
+	}
+	if uid := resItemUID(item); uid != "" {
+		walk(uid, 0)
+	}
+	return lines
+}
+
+// uncheckedResources the sorted resources, which weren't checked completely for dependents, with the reason
+func (x *ownerIndexType) uncheckedResources() []string {
+	names := []string{}
+	for n, reason := range x.unchecked {
+		names = append(names, fmt.Sprintf("%s (%s)", n, reason))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// watchedOnlyKinds the sorted kinds, whose dependents were only checked in the watched resources
+func (x *ownerIndexType) watchedOnlyKinds() []string {
+	kinds := []string{}
+	for k := range x.watchedOnly {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// previewTarget the dependents of an item to delete, for other mutations the diff of the live item and the dry run result
+func (b *backendType) previewTarget(owners *ownerIndexType, t bulkTargetType, m mutationType) []string {
+	lines := []string{colorizeText(t.String(), 0, len(t.String()), whiteEmpInlineColor)}
+	if m.method == http.MethodDelete {
+		if _, err := b.mutateObject(m, true); err != nil {
+			return append(lines, previewError("dry run failed", err))
+		}
+		deps := owners.dependents(b, t.item)
+		if len(deps) == 0 {
+			return append(lines, "  no dependents")
+		}
+		lines = append(lines, "  garbage collected with it:")
+		for _, d := range deps {
+			lines = append(lines, "  "+d)
+		}
+		return lines
+	}
+	var live map[string]interface{}
+	if m.name != "" {
+		var err error
+		if live, err = b.get(m.namespace, m.resource, m.name); err != nil {
+			return append(lines, previewError("can't get live object", err))
+		}
+	}
+	diff, err := b.previewMutation(m, live)
+	if err != nil {
+		return append(lines, previewError("dry run failed", err))
+	}
+	return append(lines, previewDiff(diff)...)
+}
+
+func previewError(mess string, err error) string {
+	return colorizeText("  "+mess+": ", 0, len(mess)+4, redEmpInlineColor) + strings.SplitN(apiErrorMessage(err), "\n", 2)[0]
+}
+
+func previewDiff(diff []string) []string {
+	if len(diff) == 0 {
+		return []string{"  no changes"}
+	}
+	lines := []string{}
+	for _, l := range diff {
+		lines = append(lines, "  "+colorizeDiffLine(l))
+	}
+	return lines
+}
+
+// bulkPreview runs the mutation of every target as dry run. The previews of the first targets are shown, of the others only failures
+func (b *backendType) bulkPreview(resources []resourceType, targets []bulkTargetType, mutation func(t bulkTargetType) mutationType) string {
+	owners := newOwnerIndex(resources)
+	lines := []string{}
+	failed := []string{}
+	for i, t := range targets {
+		if i < bulkPreviewMaxItems {
+			lines = append(lines, b.previewTarget(owners, t, mutation(t))...)
+			continue
+		}
+		if _, err := b.mutateObject(mutation(t), true); err != nil {
+			failed = append(failed, colorizeText(t.String(), 0, len(t.String()), whiteEmpInlineColor), previewError("dry run failed", err))
+		}
+	}
+	if hidden := len(targets) - bulkPreviewMaxItems; hidden > 0 {
+		lines = append(lines, fmt.Sprintf("... %d more not shown, %d of them failed the dry run", hidden, len(failed)/2))
+		lines = append(lines, failed...)
+	}
+	if unchecked := owners.uncheckedResources(); len(unchecked) > 0 {
+		mess := "dependents not checked completely: " + strings.Join(unchecked, ", ")
+		lines = append(lines, colorizeText(mess, 0, len(mess), redEmpInlineColor))
+	}
+	if kinds := owners.watchedOnlyKinds(); len(kinds) > 0 {
+		mess := "dependents of " + strings.Join(kinds, ", ") + " only checked in the watched resources"
+		lines = append(lines, colorizeText(mess, 0, len(mess), yellowEmpInlineColor))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func colorizeDiffLine(l string) string {
+	switch {
+	case strings.HasPrefix(l, "@@"):
+		return colorizeText(l, 0, len(l), cyanEmpInlineColor)
+	case strings.HasPrefix(l, "+"):
+		return colorizeText(l, 0, len(l), greenEmpInlineColor)
+	case strings.HasPrefix(l, "-"):
+		return colorizeText(l, 0, len(l), redEmpInlineColor)
+	}
+	return l
+}
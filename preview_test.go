@@ -0,0 +1,149 @@
+package kubexp
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_UnifiedDiff(t *testing.T) {
+	require := require.New(t)
+	require.Nil(unifiedDiff("a\nb\n", "a\nb", 3))
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12"
+	b := "1\n2x\n3\n4\n5\n6\n7\n8\n9\n10\n11x\n12\n13"
+	require.Equal([]string{
+		"@@ -1,5 +1,5 @@", " 1", "-2", "+2x", " 3", " 4", " 5",
+		"@@ -8,5 +8,6 @@", " 8", " 9", " 10", "-11", "+11x", " 12", "+13",
+	}, unifiedDiff(a, b, 3))
+	require.Equal([]string{"@@ -1,12 +1,13 @@"}, unifiedDiff(a, b, 5)[:1], "close hunks are joined")
+	require.Equal([]string{"@@ -0,0 +1,2 @@", "+a", "+b"}, unifiedDiff("", "a\nb\n", 3))
+
+	defer func(max int) { previewDiffMaxCells = max }(previewDiffMaxCells)
+	previewDiffMaxCells = 20
+	require.Equal([]string{"@@ -10,3 +10,3 @@", " 10", "-11", "+11x", " 12"}, unifiedDiff(a, strings.Replace(a, "11", "11x", 1), 1), "common first and last lines aren't compared")
+	require.Equal([]string{"@@ too many changed lines to diff @@"}, unifiedDiff(a, b, 3))
+}
+
+func Test_Dependents(t *testing.T) {
+	require := require.New(t)
+	requests := []string{}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path)
+		switch r.URL.Path {
+		case "/apis/apps/v1/namespaces/dev/replicasets":
+			fmt.Fprint(w, `{"kind":"ReplicaSetList","items":[
+				{"metadata":{"name":"web-1","namespace":"dev","uid":"r1","ownerReferences":[{"kind":"Deployment","name":"web","uid":"d1"}]}},
+				{"metadata":{"name":"db-1","namespace":"dev","uid":"r2","ownerReferences":[{"kind":"Deployment","name":"db","uid":"d2"}]}}]}`)
+		case "/api/v1/namespaces/dev/endpoints":
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"kind":"Status","status":"Failure","message":"endpoints is forbidden","reason":"Forbidden","code":403}`)
+		case "/apis/batch/v1/namespaces/dev/jobs":
+			fmt.Fprintf(w, `{"kind":"JobList","metadata":{"continue":"next"},"items":[{"metadata":{"name":"job-%d","namespace":"dev","uid":"j%d","ownerReferences":[{"kind":"CronJob","name":"nightly","uid":"c1"}]}}]}`, len(requests), len(requests))
+		default:
+			fmt.Fprint(w, `{"kind":"List","items":[]}`)
+		}
+	}))
+	defer server.Close()
+	be := newTestWatchBackend(server)
+	deployments := resourceType{Name: "deployments", APIPrefix: "apis/apps/v1", Namespace: true}
+	replicasets := resourceType{Name: "replicasets", APIPrefix: "apis/apps/v1", Namespace: true}
+	pods := resourceType{Name: "pods", APIPrefix: "api/v1", Namespace: true}
+	endpoints := resourceType{Name: "endpoints", APIPrefix: "api/v1", Namespace: true}
+	configmaps := resourceType{Name: "configmaps", APIPrefix: "api/v1", Namespace: true}
+	jobs := resourceType{Name: "jobs", APIPrefix: "apis/batch/v1", Namespace: true}
+	widgets := resourceType{Name: "widgets", APIPrefix: "apis/example.com/v1", Namespace: true}
+	resources := []resourceType{deployments, replicasets, pods, endpoints, configmaps, jobs, widgets}
+
+	// pods and widgets are watched, the other resources are listed
+	for _, res := range []resourceType{pods, widgets} {
+		w := newResourceWatch(res, "")
+		w.setOnline(true)
+		be.watches[w.key()] = w
+	}
+	be.store.replace("pods", []interface{}{
+		unmarshall(`{"kind":"Pod","metadata":{"name":"web-1-b","namespace":"dev","uid":"p2","ownerReferences":[{"kind":"ReplicaSet","name":"web-1","uid":"r1"}]}}`),
+		unmarshall(`{"kind":"Pod","metadata":{"name":"web-1-a","namespace":"dev","uid":"p1","ownerReferences":[{"kind":"ReplicaSet","name":"web-1","uid":"r1"}]}}`),
+		unmarshall(`{"kind":"Pod","metadata":{"name":"db-1-a","namespace":"dev","uid":"p3","ownerReferences":[{"kind":"ReplicaSet","name":"db-1","uid":"r2"}]}}`),
+	})
+	be.store.replace("example.com/widgets", []interface{}{
+		unmarshall(`{"kind":"Widget","metadata":{"name":"w","namespace":"test","uid":"w1","ownerReferences":[{"kind":"Shelf","name":"top","uid":"s1"}]}}`),
+	})
+	deployment := unmarshall(`{"kind":"Deployment","metadata":{"name":"web","namespace":"dev","uid":"d1"}}`)
+	owners := newOwnerIndex(resources)
+	require.Equal([]string{"replicasets dev/web-1", "  pods dev/web-1-a", "  pods dev/web-1-b"}, owners.dependents(be, deployment), "dependents of resources, which aren't watched, are found")
+	require.Equal([]string{"/apis/apps/v1/namespaces/dev/replicasets"}, requests, "only the resources owned by the kind are listed, watched ones are taken from the store")
+
+	require.Empty(owners.dependents(be, be.store.get("pods", "dev", "web-1-a")))
+	require.Empty(owners.dependents(be, unmarshall(`{"kind":"Deployment","metadata":{"name":"db","namespace":"dev","uid":"d3"}}`)))
+	require.Len(requests, 1, "a resource is listed once in a namespace")
+
+	require.Empty(owners.dependents(be, unmarshall(`{"kind":"Service","metadata":{"name":"web","namespace":"dev","uid":"s2"}}`)))
+	require.Equal([]string{"endpoints (can't list)"}, owners.uncheckedResources())
+
+	require.Equal([]string{"widgets test/w"}, owners.dependents(be, unmarshall(`{"kind":"Shelf","metadata":{"name":"top","uid":"s1"}}`)), "dependents of an unknown kind are searched in the watched resources")
+	require.Equal([]string{"Shelf"}, owners.watchedOnlyKinds())
+
+	defer func(max int) { dependentsMaxItems = max }(dependentsMaxItems)
+	dependentsMaxItems = 2
+	requests = []string{}
+	require.Len(owners.dependents(be, unmarshall(`{"kind":"CronJob","metadata":{"name":"nightly","namespace":"dev","uid":"c1"}}`)), 2)
+	require.Len(requests, 2, "lists are paginated and stop after dependentsMaxItems")
+	require.Equal([]string{"endpoints (can't list)", "jobs (more than 2 items)"}, owners.uncheckedResources())
+}
+
+func Test_BulkPreview(t *testing.T) {
+	require := require.New(t)
+	requests := []string{}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		requests = append(requests, fmt.Sprintf("%s %s?%s %s", r.Method, r.URL.Path, r.URL.RawQuery, body))
+		switch {
+		case r.Method == http.MethodGet:
+			fmt.Fprint(w, `{"kind":"Deployment","metadata":{"name":"web","namespace":"dev","managedFields":[{"manager":"kubectl"}]},"spec":{"replicas":1}}`)
+		case r.Method == http.MethodPatch:
+			fmt.Fprint(w, `{"kind":"Deployment","metadata":{"name":"web","namespace":"dev","managedFields":[{"manager":"kubexp"}]},"spec":{"replicas":2}}`)
+		case r.URL.Path == "/apis/apps/v1/namespaces/dev/deployments/db":
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"kind":"Status","status":"Failure","message":"deployments.apps \"db\" is forbidden","reason":"Forbidden","code":403}`)
+		default:
+			fmt.Fprint(w, `{"kind":"Status","status":"Success"}`)
+		}
+	}))
+	defer server.Close()
+	be := newTestWatchBackend(server)
+	deployments := resourceType{Name: "deployments", APIPrefix: "apis/apps/v1", Namespace: true}
+	web := unmarshall(`{"kind":"Deployment","metadata":{"name":"web","namespace":"dev","uid":"d1"},"spec":{"replicas":1}}`)
+	targets := []bulkTargetType{{namespace: "dev", name: "web", item: web}}
+
+	preview := inlineColorRegexp.ReplaceAllString(be.bulkPreview(nil, targets, func(t bulkTargetType) mutationType {
+		return scaleMutation(t.namespace, deployments, t.name, t.item, 1)
+	}), "")
+	require.Equal(`PATCH /apis/apps/v1/namespaces/dev/deployments/web?dryRun=All {"spec":{ "replicas": 2 }}`, requests[1])
+	require.Equal(strings.Join([]string{"dev/web",
+		"  @@ -3,4 +3,4 @@", "     name: web", "     namespace: dev", "   spec:", "  -  replicas: 1", "  +  replicas: 2"}, "\n"), preview, "managed fields are left out")
+
+	requests = []string{}
+	targets = append(targets, bulkTargetType{namespace: "dev", name: "db"})
+	preview = inlineColorRegexp.ReplaceAllString(be.bulkPreview(nil, targets, func(t bulkTargetType) mutationType {
+		return deleteMutation(t.namespace, deployments, t.name, true)
+	}), "")
+	require.Equal([]string{
+		"DELETE /apis/apps/v1/namespaces/dev/deployments/web?gracePeriodSeconds=0&dryRun=All ",
+		"DELETE /apis/apps/v1/namespaces/dev/deployments/db?gracePeriodSeconds=0&dryRun=All ",
+	}, requests)
+	require.Equal(strings.Join([]string{"dev/web", "  no dependents", "dev/db", `  dry run failed: deployments.apps "db" is forbidden (Forbidden)`}, "\n"), preview)
+
+	bulkPreviewMaxItems = 1
+	defer func() { bulkPreviewMaxItems = 5 }()
+	requests = []string{}
+	preview = inlineColorRegexp.ReplaceAllString(be.bulkPreview(nil, targets, func(t bulkTargetType) mutationType {
+		return deleteMutation(t.namespace, deployments, t.name, false)
+	}), "")
+	require.Len(requests, 2, "all targets run as dry run")
+	require.Equal(strings.Join([]string{"dev/web", "  no dependents", "... 1 more not shown, 1 of them failed the dry run", "dev/db", `  dry run failed: deployments.apps "db" is forbidden (Forbidden)`}, "\n"), preview)
+}
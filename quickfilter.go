@@ -0,0 +1,177 @@
+package kubexp
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"text/template"
+	"unicode"
+	"unicode/utf8"
+)
+
+// quickFilter narrows the resource item list to the items matching all of its space separated terms
+var quickFilter = ""
+
+var quickFilterHighlightColor = yellowInverseInlineColor
+
+var inlineColorRegexp = regexp.MustCompile("\033\\[[0-9;]*m")
+
+// quickFilterItems the resource items, which match the quick filter with their name, namespace, labels or row rendered with the list template
+func quickFilterItems(items []interface{}, filter string, listTpl *template.Template) []interface{} {
+	terms := strings.Fields(filter)
+	if len(terms) == 0 {
+		return items
+	}
+	ret := make([]interface{}, 0)
+	for _, ri := range items {
+		if quickFilterMatches(ri, terms, listTpl) {
+			ret = append(ret, ri)
+		}
+	}
+	return ret
+}
+
+// quickFilterCacheType the last filtered items, the list is updated every tick while its sorted items and the filter rarely change
+type quickFilterCacheType struct {
+	first   *interface{}
+	len     int
+	filter  string
+	listTpl *template.Template
+	items   []interface{}
+}
+
+var quickFilterCache quickFilterCacheType
+
+var quickFilterCacheMutex = &sync.Mutex{}
+
+// cachedQuickFilterItems like quickFilterItems, the items are only filtered again when the sorted items, the filter or the list template changed.
+// The sorted items are identified by their slice, which the backend replaces when they change
+func cachedQuickFilterItems(items []interface{}, filter string, listTpl *template.Template) []interface{} {
+	if len(items) == 0 {
+		return quickFilterItems(items, filter, listTpl)
+	}
+	quickFilterCacheMutex.Lock()
+	c := quickFilterCache
+	quickFilterCacheMutex.Unlock()
+	if c.first == &items[0] && c.len == len(items) && c.filter == filter && c.listTpl == listTpl {
+		return c.items
+	}
+	filtered := quickFilterItems(items, filter, listTpl)
+	quickFilterCacheMutex.Lock()
+	quickFilterCache = quickFilterCacheType{first: &items[0], len: len(items), filter: filter, listTpl: listTpl, items: filtered}
+	quickFilterCacheMutex.Unlock()
+	return filtered
+}
+
+func quickFilterMatches(ri interface{}, terms []string, listTpl *template.Template) bool {
+	fields := []string{resItemName(ri), resItemNamespace(ri)}
+	if m, ok := ri.(map[string]interface{}); ok {
+		if meta, ok := m["metadata"].(map[string]interface{}); ok {
+			if labels, ok := meta["labels"].(map[string]interface{}); ok {
+				for k, v := range labels {
+					fields = append(fields, fmt.Sprintf("%s=%v", k, v))
+				}
+			}
+		}
+	}
+	if listTpl != nil {
+		buf := new(bytes.Buffer)
+		if err := listTpl.Execute(buf, ri); err == nil {
+			fields = append(fields, inlineColorRegexp.ReplaceAllString(buf.String(), ""))
+		}
+	}
+	for _, term := range terms {
+		found := false
+		for _, f := range fields {
+			if fuzzyMatch(term, f) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// fuzzyMatch true if the characters of pattern appear in text in the same order, case is ignored
+func fuzzyMatch(pattern, text string) bool {
+	return fuzzyPositions(pattern, []rune(text)) != nil
+}
+
+// fuzzyPositions indexes of the runes of text matching the runes of pattern, nil if pattern doesn't match
+func fuzzyPositions(pattern string, text []rune) []int {
+	pos := []int{}
+	i := 0
+	for _, p := range pattern {
+		p = unicode.ToLower(p)
+		for i < len(text) && unicode.ToLower(text[i]) != p {
+			i++
+		}
+		if i == len(text) {
+			return nil
+		}
+		pos = append(pos, i)
+		i++
+	}
+	return pos
+}
+
+// highlightMatches colorizes the characters of the rendered row, which match the terms of the filter. The inline colors of the row are kept
+func highlightMatches(row, filter string) string {
+	terms := strings.Fields(filter)
+	if len(terms) == 0 {
+		return row
+	}
+	// visible runes of the row with their byte offsets, inline colors are skipped
+	runes, offsets := []rune{}, []int{}
+	for i := 0; i < len(row); {
+		if loc := inlineColorRegexp.FindStringIndex(row[i:]); loc != nil && loc[0] == 0 {
+			i += loc[1]
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(row[i:])
+		runes = append(runes, r)
+		offsets = append(offsets, i)
+		i += size
+	}
+	matched := map[int]bool{}
+	for _, term := range terms {
+		for _, p := range fuzzyPositions(term, runes) {
+			matched[offsets[p]] = true
+		}
+	}
+	if len(matched) == 0 {
+		return row
+	}
+	var sb strings.Builder
+	activeColor := ""
+	for i := 0; i < len(row); {
+		if loc := inlineColorRegexp.FindStringIndex(row[i:]); loc != nil && loc[0] == 0 {
+			activeColor = row[i : i+loc[1]]
+			sb.WriteString(activeColor)
+			if activeColor == inlineColorEnd {
+				activeColor = ""
+			}
+			i += loc[1]
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(row[i:])
+		if !matched[i] {
+			sb.WriteString(row[i : i+size])
+			i += size
+			continue
+		}
+		end := i + size
+		for end < len(row) && matched[end] {
+			_, size = utf8.DecodeRuneInString(row[end:])
+			end += size
+		}
+		sb.WriteString(colorizeText(row[i:end], 0, end-i, quickFilterHighlightColor) + activeColor)
+		i = end
+	}
+	return sb.String()
+}
@@ -0,0 +1,62 @@
+package kubexp
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_FuzzyMatch(t *testing.T) {
+	require := require.New(t)
+	require.True(fuzzyMatch("wb", "web-1"))
+	require.True(fuzzyMatch("WEB", "web-1"))
+	require.True(fuzzyMatch("", "web-1"))
+	require.False(fuzzyMatch("bw", "web-1"))
+	require.False(fuzzyMatch("web-12", "web-1"))
+	require.Equal([]int{0, 2, 4}, fuzzyPositions("wb1", []rune("web-1")))
+}
+
+func Test_QuickFilterItems(t *testing.T) {
+	require := require.New(t)
+	items := []interface{}{
+		unmarshall(`{"metadata":{"name":"web-1","namespace":"dev","labels":{"app":"frontend"}},"status":{"phase":"Running"}}`),
+		unmarshall(`{"metadata":{"name":"db-1","namespace":"staging","labels":{"app":"postgres"}},"status":{"phase":"Failed"}}`),
+	}
+	listTpl := tpl("quickFilterTest", `{{ .metadata.name }} {{ .status.phase | printf "%s" | redEmp }}`)
+	names := func(filter string) []string {
+		ret := []string{}
+		for _, ri := range quickFilterItems(items, filter, listTpl) {
+			ret = append(ret, resItemName(ri))
+		}
+		return ret
+	}
+	require.Equal([]string{"web-1", "db-1"}, names(""))
+	require.Equal([]string{"web-1"}, names("wb"), "name")
+	require.Equal([]string{"db-1"}, names("stg"), "namespace")
+	require.Equal([]string{"db-1"}, names("app=pg"), "label")
+	require.Equal([]string{"db-1"}, names("fail"), "rendered row")
+	require.Equal([]string{"web-1"}, names("dev run"), "all terms")
+	require.Empty(names("dev fail"))
+
+	filtered := cachedQuickFilterItems(items, "wb", listTpl)
+	require.Len(filtered, 1)
+	items[0].(map[string]interface{})["metadata"].(map[string]interface{})["name"] = "api-1"
+	require.Equal(filtered, cachedQuickFilterItems(items, "wb", listTpl), "same sorted items, cached")
+	require.Empty(cachedQuickFilterItems(items, "wb", tpl("quickFilterTest2", `{{ .metadata.name }}`)), "other template")
+	require.Len(cachedQuickFilterItems(append([]interface{}{}, items...), "api", listTpl), 1, "other sorted items")
+}
+
+func Test_HighlightMatches(t *testing.T) {
+	require := require.New(t)
+	hl := func(text string) string {
+		return colorizeText(text, 0, len(text), quickFilterHighlightColor)
+	}
+	require.Equal("web-1 dev", highlightMatches("web-1 dev", ""))
+	require.Equal(hl("we")+"b-"+hl("1")+" dev", highlightMatches("web-1 dev", "we1"))
+	require.Equal("web-1 dev", highlightMatches("web-1 dev", "xyz"))
+
+	row := "db " + colorizeText("Pending", 0, 7, redEmpInlineColor)
+	red := fmt.Sprintf(inlineColorStart, redEmpInlineColor.p1, redEmpInlineColor.p2)
+	require.Equal("db "+red+hl("Pen")+red+"ding"+inlineColorEnd, highlightMatches(row, "pen"), "inline color of the row is continued")
+}
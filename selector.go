@@ -0,0 +1,205 @@
+package kubexp
+
+import (
+	"fmt"
+	neturl "net/url"
+	"regexp"
+	"strings"
+)
+
+// serverSelector selectors are sent to the api server with the list and watch of the resource, so only the selected items are cached
+var serverSelector = false
+
+// requirements with a key starting with one of these are field selectors, all others label selectors
+var fieldSelectorRoots = []string{"metadata.", "spec.", "status.", "involvedObject.", "source."}
+
+var setRequirementRegexp = regexp.MustCompile(`^(\S+)\s+(in|notin)\s+\((.*)\)$`)
+
+// selectorType label selector, e.g. 'app=web,tier!=cache,env in (dev,test)', and field selector, e.g. 'status.phase=Running', of the items of a resource
+type selectorType struct {
+	labels []requirementType
+	fields []requirementType
+}
+
+type requirementType struct {
+	key, op string
+	values  []string
+}
+
+// parseSelector parses comma separated label and field requirements
+func parseSelector(text string) (selectorType, error) {
+	sel := selectorType{}
+	for _, term := range splitSelector(text) {
+		req, err := parseRequirement(term)
+		if err != nil {
+			return selectorType{}, err
+		}
+		if req.isField() {
+			if req.op != "=" && req.op != "!=" {
+				return selectorType{}, fmt.Errorf("field selector '%s' supports only '=', '==' and '!='", term)
+			}
+			sel.fields = append(sel.fields, req)
+		} else {
+			sel.labels = append(sel.labels, req)
+		}
+	}
+	return sel, nil
+}
+
+// splitSelector splits at the commas, which are not in the value set of an 'in' or 'notin' requirement
+func splitSelector(text string) []string {
+	terms := []string{}
+	depth, start := 0, 0
+	for i, c := range text {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				terms = append(terms, text[start:i])
+				start = i + 1
+			}
+		}
+	}
+	terms = append(terms, text[start:])
+	ret := []string{}
+	for _, t := range terms {
+		if t = strings.TrimSpace(t); t != "" {
+			ret = append(ret, t)
+		}
+	}
+	return ret
+}
+
+func parseRequirement(term string) (requirementType, error) {
+	req := requirementType{}
+	if m := setRequirementRegexp.FindStringSubmatch(term); m != nil {
+		req.key, req.op = m[1], m[2]
+		for _, v := range strings.Split(m[3], ",") {
+			req.values = append(req.values, strings.TrimSpace(v))
+		}
+	} else {
+		for _, op := range []string{"!=", "==", "="} {
+			if i := strings.Index(term, op); i >= 0 {
+				req.key, req.op, req.values = strings.TrimSpace(term[:i]), op, []string{strings.TrimSpace(term[i+len(op):])}
+				break
+			}
+		}
+		if req.op == "==" {
+			req.op = "="
+		}
+		if req.op == "" {
+			req.key, req.op = term, "exists"
+			if strings.HasPrefix(term, "!") {
+				req.key, req.op = strings.TrimSpace(term[1:]), "!exists"
+			}
+		}
+	}
+	if req.key == "" || strings.ContainsAny(req.key, " \t()!=") {
+		return requirementType{}, fmt.Errorf("invalid selector requirement '%s'", term)
+	}
+	for _, v := range req.values {
+		if strings.ContainsAny(v, " \t()!=") {
+			return requirementType{}, fmt.Errorf("invalid value in selector requirement '%s'", term)
+		}
+	}
+	return req, nil
+}
+
+func (r requirementType) isField() bool {
+	for _, root := range fieldSelectorRoots {
+		if strings.HasPrefix(r.key, root) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r requirementType) matches(value string, found bool) bool {
+	switch r.op {
+	case "exists":
+		return found
+	case "!exists":
+		return !found
+	case "=":
+		return found && value == r.values[0]
+	case "!=":
+		return !found || value != r.values[0]
+	}
+	in := false
+	for _, v := range r.values {
+		in = in || found && value == v
+	}
+	return in == (r.op == "in")
+}
+
+func (r requirementType) String() string {
+	switch r.op {
+	case "exists":
+		return r.key
+	case "!exists":
+		return "!" + r.key
+	case "in", "notin":
+		return fmt.Sprintf("%s %s (%s)", r.key, r.op, strings.Join(r.values, ","))
+	}
+	return r.key + r.op + r.values[0]
+}
+
+func (s selectorType) isEmpty() bool {
+	return len(s.labels) == 0 && len(s.fields) == 0
+}
+
+// matches true if the labels and the fields of the resource item ri fulfill all requirements
+func (s selectorType) matches(ri interface{}) bool {
+	labels := map[string]interface{}{}
+	if m, ok := ri.(map[string]interface{}); ok {
+		if meta, ok := m["metadata"].(map[string]interface{}); ok {
+			if l, ok := meta["labels"].(map[string]interface{}); ok {
+				labels = l
+			}
+		}
+	}
+	for _, req := range s.labels {
+		value, found := labels[req.key]
+		if !req.matches(fmt.Sprintf("%v", value), found) {
+			return false
+		}
+	}
+	for _, req := range s.fields {
+		values := evalJSONPath([]interface{}{ri}, "."+req.key)
+		value := ""
+		if len(values) > 0 {
+			value = jsonPathString(values[0])
+		}
+		if !req.matches(value, true) {
+			return false
+		}
+	}
+	return true
+}
+
+// query parameters of list and watch requests with the selector
+func (s selectorType) query() string {
+	params := []string{}
+	if len(s.labels) > 0 {
+		params = append(params, "labelSelector="+neturl.QueryEscape(joinRequirements(s.labels)))
+	}
+	if len(s.fields) > 0 {
+		params = append(params, "fieldSelector="+neturl.QueryEscape(joinRequirements(s.fields)))
+	}
+	return strings.Join(params, "&")
+}
+
+func (s selectorType) String() string {
+	return joinRequirements(append(append([]requirementType{}, s.labels...), s.fields...))
+}
+
+func joinRequirements(reqs []requirementType) string {
+	strs := make([]string, len(reqs))
+	for i, r := range reqs {
+		strs[i] = r.String()
+	}
+	return strings.Join(strs, ",")
+}
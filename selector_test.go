@@ -0,0 +1,107 @@
+package kubexp
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ParseSelector(t *testing.T) {
+	require := require.New(t)
+	sel, err := parseSelector("app=web, tier!=cache,env in (dev, test),!legacy,canary,status.phase==Running,spec.nodeName!=node1")
+	require.Nil(err)
+	require.Len(sel.labels, 5)
+	require.Len(sel.fields, 2)
+	require.Equal("app=web,tier!=cache,env in (dev,test),!legacy,canary,status.phase=Running,spec.nodeName!=node1", sel.String())
+	require.Equal("labelSelector=app%3Dweb%2Ctier%21%3Dcache%2Cenv+in+%28dev%2Ctest%29%2C%21legacy%2Ccanary&fieldSelector=status.phase%3DRunning%2Cspec.nodeName%21%3Dnode1", sel.query())
+
+	sel, err = parseSelector("  ")
+	require.Nil(err)
+	require.True(sel.isEmpty())
+
+	for _, invalid := range []string{"=web", "status.phase in (Running)", "app=w b", "a b"} {
+		_, err = parseSelector(invalid)
+		require.NotNil(err, invalid)
+	}
+}
+
+func Test_SelectorMatches(t *testing.T) {
+	require := require.New(t)
+	pod := unmarshall(`{"metadata":{"name":"web-1","labels":{"app":"web","env":"dev"}},"spec":{"nodeName":"node1"},"status":{"phase":"Running"}}`)
+	for text, expected := range map[string]bool{
+		"app=web":                      true,
+		"app=db":                       false,
+		"app!=db":                      true,
+		"tier!=cache":                  true,
+		"env in (dev,test)":            true,
+		"env notin (dev,test)":         false,
+		"tier notin (cache)":           true,
+		"app":                          true,
+		"!app":                         false,
+		"!tier":                        true,
+		"status.phase=Running":         true,
+		"spec.nodeName=node2":          false,
+		"app=web,spec.nodeName!=node2": true,
+		"metadata.name=web-1":          true,
+	} {
+		sel, err := parseSelector(text)
+		require.Nil(err)
+		require.Equal(expected, sel.matches(pod), text)
+	}
+}
+
+func Test_SelectorFiltersResourceItems(t *testing.T) {
+	require := require.New(t)
+	be := newTestWatchBackend(nil)
+	podType := resourceType{Name: "pods", Namespace: true}
+	be.store.replace("pods", []interface{}{
+		unmarshall(`{"metadata":{"name":"web","namespace":"dev","labels":{"app":"web"}}}`),
+		unmarshall(`{"metadata":{"name":"db","namespace":"dev","labels":{"app":"db"}}}`),
+	})
+	require.Len(be.resourceItems("dev", podType), 2)
+
+	sel, _ := parseSelector("app=web")
+	be.setSelector("pods", sel)
+	items := be.resourceItems("dev", podType)
+	require.Len(items, 1)
+	require.Equal("web", resItemName(items[0]))
+
+	be.setSelector("pods", selectorType{})
+	require.Len(be.resourceItems("dev", podType), 2)
+}
+
+func Test_SelectorIsSentToServer(t *testing.T) {
+	require := require.New(t)
+	serverSelector = true
+	defer func() { serverSelector = false }()
+	defer func(d time.Duration) { watchMinDuration = d }(watchMinDuration)
+	watchMinDuration = 0
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/pods":
+			fmt.Fprintf(w, `{"kind":"PodList","apiVersion":"v1","metadata":{"resourceVersion":"10"},"items":[{"metadata":{"name":"%s","namespace":"dev"}}]}`, r.URL.Query().Get("labelSelector"))
+		default:
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		}
+	}))
+	defer server.Close()
+
+	be := newTestWatchBackend(server)
+	require.Nil(be.watch(podResource))
+	require.NotNil(be.store.get("pods", "dev", ""))
+
+	sel, _ := parseSelector("app=web")
+	be.setSelector("pods", sel)
+	require.Nil(be.watchOf("pods"), "watch is restarted with the selector")
+	require.Nil(be.watch(podResource))
+	defer be.watchOf("pods").close()
+	require.NotNil(be.store.get("pods", "dev", "app=web"))
+	require.Len(be.store.list("pods"), 1)
+	require.Eventually(func() bool { return be.watchState("pods") == watchOnline }, 5*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,245 @@
+package kubexp
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// sortOrderType how the items of a resource are sorted, the zero value sorts by name ascending. Other columns are sorted by their values in the list view listTpl
+type sortOrderType struct {
+	column     string
+	descending bool
+	listTpl    *template.Template
+}
+
+var ratioRegexp = regexp.MustCompile(`^(\d+)/(\d+)$`)
+
+// ages as rendered by time2age, e.g. '1y2m', '3m4d', '5d6h', '7h8m', '9m10s', '11s'
+var ageRegexp = regexp.MustCompile(`^(\d+)([ymdhs])(?:(\d+)([mdhs]))?$`)
+
+// sortColumn header of the column the items are sorted by
+func (o sortOrderType) sortColumn() string {
+	if o.column == "" {
+		return "Name"
+	}
+	return o.column
+}
+
+func (o sortOrderType) String() string {
+	return fmt.Sprintf("%s/%v", o.sortColumn(), o.descending)
+}
+
+// newSorter the name and age columns are sorted by name and namespace and by creation time, all others by the values shown in the list view
+func (o sortOrderType) newSorter() sorterType {
+	switch o.sortColumn() {
+	case "Name":
+		return &nameSorterType{ascending: !o.descending}
+	case "Age":
+		return &timeSorterType{ascending: !o.descending}
+	}
+	if o.listTpl == nil {
+		return &nameSorterType{ascending: !o.descending}
+	}
+	return &columnSorterType{ascending: !o.descending, column: o.column, listTpl: o.listTpl}
+}
+
+// columnCaptureType executes a list view and captures the values of its columns, instead of the rendered text
+type columnCaptureType struct {
+	tpl    *template.Template
+	names  []string
+	values map[string]string
+}
+
+func newColumnCapture(listTpl *template.Template) *columnCaptureType {
+	c := &columnCaptureType{values: map[string]string{}}
+	clone, err := listTpl.Clone()
+	if err != nil {
+		errorlog.Printf("can't clone template %s: %v", listTpl.Name(), err)
+		return c
+	}
+	c.tpl = clone.Funcs(template.FuncMap{
+		"header": func(name string, rootVal, val interface{}) interface{} {
+			v := header(name, rootVal, val)
+			c.names = append(c.names, name)
+			c.values[name] = inlineColorRegexp.ReplaceAllString(strings.TrimSpace(fmt.Sprintf("%v", v)), "")
+			return v
+		},
+		// the values must not be blanked while they blink
+		"blinkWhenChanged": func(it interface{}, resName string, text string) string {
+			return text
+		},
+	})
+	return c
+}
+
+// capture the values of the columns of the resource item ri by column header
+func (c *columnCaptureType) capture(ri interface{}) map[string]string {
+	c.names, c.values = nil, map[string]string{}
+	if c.tpl != nil {
+		c.tpl.Execute(ioutil.Discard, ri)
+	}
+	return c.values
+}
+
+// listColumns headers of the columns of the list view
+func listColumns(listTpl *template.Template) []string {
+	c := newColumnCapture(listTpl)
+	c.capture(map[string]interface{}{"header": "true"})
+	return c.names
+}
+
+// sortKeyType value of the sort column of an item, number is used when all values of the column could be converted to numbers
+type sortKeyType struct {
+	text   string
+	number float64
+}
+
+// columnSorterType sorts by the values of a column of the list view, numbers, ratios like ready counts, quantities and ages by their amount
+type columnSorterType struct {
+	elements  []interface{}
+	keys      []sortKeyType
+	numeric   bool
+	ascending bool
+	column    string
+	listTpl   *template.Template
+}
+
+func (s *columnSorterType) getName() string {
+	return "ColumnSorter/" + s.column
+}
+
+func (s *columnSorterType) getAscending() bool {
+	return s.ascending
+}
+
+func (s *columnSorterType) setAscending(asc bool) {
+	s.ascending = asc
+}
+
+func (s *columnSorterType) Len() int {
+	return len(s.elements)
+}
+
+func (s *columnSorterType) Swap(i, j int) {
+	s.elements[i], s.elements[j] = s.elements[j], s.elements[i]
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+}
+
+// setElements captures the values of the sort column and decides, how they are compared
+func (s *columnSorterType) setElements(ele []interface{}) {
+	s.elements = ele
+	s.keys = make([]sortKeyType, len(ele))
+	c := newColumnCapture(s.listTpl)
+	for i, ri := range ele {
+		s.keys[i].text = c.capture(ri)[s.column]
+	}
+	s.numeric = false
+	for _, conv := range []func(string) (float64, bool){parseNumber, parseRatio, parseQuantity, parseAge} {
+		if s.convertKeys(conv) {
+			s.numeric = true
+			return
+		}
+	}
+}
+
+// convertKeys true if all values, which aren't empty, can be converted
+func (s *columnSorterType) convertKeys(conv func(string) (float64, bool)) bool {
+	numbers := make([]float64, len(s.keys))
+	for i, k := range s.keys {
+		if k.text == "" {
+			continue
+		}
+		n, ok := conv(k.text)
+		if !ok {
+			return false
+		}
+		numbers[i] = n
+	}
+	for i := range s.keys {
+		s.keys[i].number = numbers[i]
+	}
+	return true
+}
+
+func (s *columnSorterType) getElements() []interface{} {
+	return s.elements
+}
+
+// Less empty values come first, equal values are sorted by name
+func (s *columnSorterType) Less(i, j int) bool {
+	ki, kj := s.keys[i], s.keys[j]
+	var cmp int
+	switch {
+	case ki.text == "" || kj.text == "":
+		cmp = strings.Compare(ki.text, kj.text)
+	case s.numeric && ki.number < kj.number:
+		cmp = -1
+	case s.numeric && ki.number > kj.number:
+		cmp = 1
+	case !s.numeric:
+		cmp = strings.Compare(ki.text, kj.text)
+	}
+	if cmp == 0 {
+		return compareNames(s.elements[i], s.elements[j]) < 0
+	}
+	return (cmp < 0) == s.ascending
+}
+
+func parseNumber(text string) (float64, bool) {
+	n, err := strconv.ParseFloat(text, 64)
+	return n, err == nil
+}
+
+// parseRatio ready counts like '2/3' are sorted by the ready ones, then by all
+func parseRatio(text string) (float64, bool) {
+	m := ratioRegexp.FindStringSubmatch(text)
+	if m == nil {
+		return 0, false
+	}
+	n, _ := strconv.ParseFloat(m[1], 64)
+	d, _ := strconv.ParseFloat(m[2], 64)
+	return n*1e6 + d, true
+}
+
+func parseQuantity(text string) (float64, bool) {
+	q, err := ParseQuantity(text)
+	if err != nil {
+		return 0, false
+	}
+	n, err := strconv.ParseFloat(q.Amount.String(), 64)
+	return n, err == nil
+}
+
+// parseAge seconds of an age, 'm' is a month in '1y2m' and '3m4d', but a minute in '7h8m' and '9m10s'
+func parseAge(text string) (float64, bool) {
+	m := ageRegexp.FindStringSubmatch(text)
+	if m == nil {
+		return 0, false
+	}
+	seconds := func(value, unit string, month bool) float64 {
+		n, _ := strconv.ParseFloat(value, 64)
+		switch unit {
+		case "y":
+			return n * 365 * 86400
+		case "m":
+			if month {
+				return n * 30 * 86400
+			}
+			return n * 60
+		case "d":
+			return n * 86400
+		case "h":
+			return n * 3600
+		}
+		return n
+	}
+	age := seconds(m[1], m[2], m[4] == "d")
+	if m[3] != "" {
+		age += seconds(m[3], m[4], m[2] == "y")
+	}
+	return age, true
+}
@@ -0,0 +1,107 @@
+package kubexp
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ColumnSorter(t *testing.T) {
+	require := require.New(t)
+	be := newBackend(contextType{Name: "test"})
+	podType := resourceType{Name: "pods", Namespace: true}
+	pod := func(name string, restarts int, ready, cpu, node, phase string) interface{} {
+		return unmarshall(fmt.Sprintf(`{"metadata":{"name":"%s","namespace":"dev"},"spec":{"nodeName":"%s","containers":[{"resources":{"requests":{"cpu":"%s"}}}]},
+			"status":{"phase":"%s","ready":"%s","containerStatuses":[{"restartCount":%d}]}}`, name, node, cpu, phase, ready, restarts))
+	}
+	be.store.replace("pods", []interface{}{
+		pod("a", 10, "1/2", "1", "node2", "Running"),
+		pod("b", 2, "2/2", "500m", "node1", "Pending"),
+		pod("c", 2, "0/2", "1500m", "node3", "Running"),
+	})
+	listTpl := tpl("sorterTest", `{{- header "Name" . .metadata.name | printf "%-10.10s " -}}
+{{- header "Status" . ( .status.phase | printf "%s" | blinkWhenChanged . "pods" ) | printf "%-10.10s " | colorPhase -}}
+{{- header "Ready" . .status.ready | printf "%-5.5s " -}}
+{{- header "RC" . ( ind .status.containerStatuses 0).restartCount | printf "%-3.3s " -}}
+{{- header "CPU" . (( ind .spec.containers 0).resources.requests.cpu) | printf "%-6.6s " -}}
+{{- header "Node" . .spec.nodeName | printf "%-10.10s " -}}`)
+	require.Equal([]string{"Name", "Status", "Ready", "RC", "CPU", "Node"}, listColumns(listTpl))
+
+	sorted := func(column string, descending bool) []string {
+		be.setSortOrder("pods", sortOrderType{column: column, descending: descending, listTpl: listTpl})
+		names := []string{}
+		for _, ri := range be.resourceItems("dev", podType) {
+			names = append(names, resItemName(ri))
+		}
+		return names
+	}
+	require.Equal([]string{"b", "c", "a"}, sorted("RC", false), "numbers, equal ones by name")
+	require.Equal([]string{"a", "b", "c"}, sorted("RC", true))
+	require.Equal([]string{"c", "a", "b"}, sorted("Ready", false), "ready counts")
+	require.Equal([]string{"b", "a", "c"}, sorted("CPU", false), "quantities")
+	require.Equal([]string{"b", "a", "c"}, sorted("Node", false))
+	require.Equal([]string{"b", "a", "c"}, sorted("Status", false), "colored values")
+	require.Equal([]string{"c", "b", "a"}, sorted("Name", true))
+}
+
+func Test_AgeSorterKeepsOrderOfEqualTimes(t *testing.T) {
+	require := require.New(t)
+	pod := func(name, created string) interface{} {
+		return unmarshall(fmt.Sprintf(`{"metadata":{"name":"%s","namespace":"dev","creationTimestamp":"%s"}}`, name, created))
+	}
+	sorted := func(ascending bool, items ...interface{}) []string {
+		sorter := &timeSorterType{ascending: ascending}
+		sorter.setElements(items)
+		sort.Sort(sorter)
+		names := []string{}
+		for _, ri := range sorter.getElements() {
+			names = append(names, resItemName(ri))
+		}
+		return names
+	}
+	old, web1, web2, web3 := pod("old", "2020-01-01T10:00:00Z"), pod("web-1", "2020-01-02T10:00:00Z"), pod("web-2", "2020-01-02T10:00:00Z"), pod("web-3", "2020-01-02T10:00:00Z")
+	for _, items := range [][]interface{}{{old, web1, web2, web3}, {web3, web1, old, web2}, {web2, web3, web1, old}} {
+		require.Equal([]string{"old", "web-1", "web-2", "web-3"}, sorted(true, items...), "pods created in the same second by name")
+		require.Equal([]string{"web-1", "web-2", "web-3", "old"}, sorted(false, items...))
+	}
+}
+
+func Test_SortValues(t *testing.T) {
+	require := require.New(t)
+	for text, expected := range map[string]float64{
+		"11s":   11,
+		"9m10s": 9*60 + 10,
+		"7h8m":  7*3600 + 8*60,
+		"5d6h":  5*86400 + 6*3600,
+		"3m4d":  3*30*86400 + 4*86400,
+		"1y2m":  365*86400 + 2*30*86400,
+	} {
+		age, ok := parseAge(text)
+		require.True(ok, text)
+		require.Equal(expected, age, text)
+	}
+	_, ok := parseAge("node1")
+	require.False(ok)
+
+	q, ok := parseQuantity("1500m")
+	require.True(ok)
+	require.Equal(1.5, q)
+	q, _ = parseQuantity("1Ki")
+	require.Equal(1024.0, q)
+	_, ok = parseQuantity("10.0.0.1")
+	require.False(ok)
+
+	r, ok := parseRatio("1/3")
+	require.True(ok)
+	r2, _ := parseRatio("2/2")
+	require.True(r < r2)
+}
+
+func Test_HeaderShowsSortOrder(t *testing.T) {
+	require := require.New(t)
+	require.Equal("RC↑", header("RC", map[string]interface{}{"header": "true", "sortColumn": "RC", "sortDescending": false}, nil))
+	require.Equal("RC↓", header("RC", map[string]interface{}{"header": "true", "sortColumn": "RC", "sortDescending": true}, nil))
+	require.Equal("Node", header("Node", map[string]interface{}{"header": "true", "sortColumn": "RC"}, nil))
+}
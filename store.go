@@ -0,0 +1,240 @@
+package kubexp
+
+import (
+	"fmt"
+	"sync"
+)
+
+// storeType holds the items of the watched resources, it is written by the watches and read by the ui
+type storeType struct {
+	mutex     sync.RWMutex
+	resources map[string]*resourceIndexType
+}
+
+// resourceIndexType items of one resource by namespace/name and by namespace, version is incremented on every change.
+// When the api server renders tables, the columns and the cells of the items by namespace/name are kept as well
+type resourceIndexType struct {
+	items       map[string]interface{}
+	byNamespace map[string]map[string]interface{}
+	version     int
+	columns     []tableColumnType
+	cells       map[string][]interface{}
+}
+
+func newStore() *storeType {
+	return &storeType{resources: map[string]*resourceIndexType{}}
+}
+
+func newResourceIndex() *resourceIndexType {
+	return &resourceIndexType{items: map[string]interface{}{}, byNamespace: map[string]map[string]interface{}{}, cells: map[string][]interface{}{}}
+}
+
+func storeKey(ns, name string) string {
+	return ns + "/" + name
+}
+
+func (s *storeType) index(resKey string) *resourceIndexType {
+	idx := s.resources[resKey]
+	if idx == nil {
+		idx = newResourceIndex()
+		s.resources[resKey] = idx
+	}
+	return idx
+}
+
+// list returns a snapshot of the items of the resource, it can be sorted and filtered without affecting the store
+func (s *storeType) list(resKey string) []interface{} {
+	items, _ := s.snapshot(resKey, "*ALL*")
+	return items
+}
+
+// snapshot returns the items of the resource in namespace ns, or all items for '*ALL*', together with the version of the resource
+func (s *storeType) snapshot(resKey, ns string) ([]interface{}, int) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	idx := s.resources[resKey]
+	if idx == nil {
+		return []interface{}{}, 0
+	}
+	items := idx.items
+	if ns != "*ALL*" {
+		items = idx.byNamespace[ns]
+	}
+	snapshot := make([]interface{}, 0, len(items))
+	for _, ri := range items {
+		snapshot = append(snapshot, ri)
+	}
+	return snapshot, idx.version
+}
+
+func (s *storeType) version(resKey string) int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if idx := s.resources[resKey]; idx != nil {
+		return idx.version
+	}
+	return 0
+}
+
+func (s *storeType) get(resKey, ns, name string) interface{} {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if idx := s.resources[resKey]; idx != nil {
+		return idx.items[storeKey(ns, name)]
+	}
+	return nil
+}
+
+// replace sets all items of the resource, e.g. after listing it
+func (s *storeType) replace(resKey string, items []interface{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	idx := newResourceIndex()
+	idx.version = 1
+	if old := s.resources[resKey]; old != nil {
+		idx.version = old.version + 1
+	}
+	for _, ri := range items {
+		idx.put(ri)
+	}
+	s.resources[resKey] = idx
+}
+
+// replaceNamespace sets the items of the resource in namespace ns, the items of other namespaces are kept
+func (s *storeType) replaceNamespace(resKey, ns string, items []interface{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	idx := s.index(resKey)
+	for name := range idx.byNamespace[ns] {
+		delete(idx.items, storeKey(ns, name))
+		delete(idx.cells, storeKey(ns, name))
+	}
+	delete(idx.byNamespace, ns)
+	for _, ri := range items {
+		idx.put(ri)
+	}
+	idx.version++
+}
+
+// drop removes all items of the resource
+func (s *storeType) drop(resKey string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.drop0(resKey)
+}
+
+// reset removes the items of all resources, the store is kept since the ui reads it concurrently
+func (s *storeType) reset() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for resKey := range s.resources {
+		s.drop0(resKey)
+	}
+}
+
+// drop0 the version keeps increasing, so the items sorted before are sorted again, s.mutex must be held
+func (s *storeType) drop0(resKey string) {
+	if old := s.resources[resKey]; old != nil {
+		idx := newResourceIndex()
+		idx.version = old.version + 1
+		s.resources[resKey] = idx
+	}
+}
+
+// upsert replaces the item with the same namespace and name or adds it, returns true if it was added
+func (s *storeType) upsert(resKey string, ri interface{}) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	idx := s.index(resKey)
+	old := idx.items[storeKey(resItemNamespace(ri), resItemName(ri))]
+	if old != nil && resItemUID(old) != resItemUID(ri) {
+		tracelog.Printf("ri (%s/%s) was recreated", resKey, resItemName(ri))
+	}
+	idx.put(ri)
+	idx.version++
+	return old == nil
+}
+
+// delete removes the item with the same namespace and name, returns false if there is none.
+// A delete for a former item with the same name, which was recreated meanwhile, is ignored
+func (s *storeType) delete(resKey string, ri interface{}) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	idx := s.index(resKey)
+	ns, name := resItemNamespace(ri), resItemName(ri)
+	old := idx.items[storeKey(ns, name)]
+	if old == nil {
+		return false
+	}
+	if uid := resItemUID(ri); uid != "" && resItemUID(old) != "" && uid != resItemUID(old) {
+		tracelog.Printf("ignoring delete of former ri (%s/%s) with uid %s", resKey, name, uid)
+		return false
+	}
+	delete(idx.items, storeKey(ns, name))
+	delete(idx.cells, storeKey(ns, name))
+	delete(idx.byNamespace[ns], name)
+	if len(idx.byNamespace[ns]) == 0 {
+		delete(idx.byNamespace, ns)
+	}
+	idx.version++
+	return true
+}
+
+// setTable keeps the columns, if the table has them, and the cells of the rows of the table
+func (s *storeType) setTable(resKey string, table tableType) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	idx := s.index(resKey)
+	if len(table.ColumnDefinitions) > 0 {
+		idx.columns = table.ColumnDefinitions
+	}
+	for _, row := range table.Rows {
+		idx.cells[storeKey(resItemNamespace(row.Object), resItemName(row.Object))] = row.Cells
+	}
+}
+
+func (s *storeType) columns(resKey string) []tableColumnType {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if idx := s.resources[resKey]; idx != nil {
+		return idx.columns
+	}
+	return nil
+}
+
+func (s *storeType) cells(resKey, ns, name string) []interface{} {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if idx := s.resources[resKey]; idx != nil {
+		return idx.cells[storeKey(ns, name)]
+	}
+	return nil
+}
+
+// cellWidths widths of the widest cells of the columns
+func (s *storeType) cellWidths(resKey string) []int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	idx := s.resources[resKey]
+	if idx == nil {
+		return nil
+	}
+	widths := make([]int, len(idx.columns))
+	for _, cells := range idx.cells {
+		for i, cell := range cells {
+			if width := len(fmt.Sprintf("%v", header("", nil, cell))); i < len(widths) && width > widths[i] {
+				widths[i] = width
+			}
+		}
+	}
+	return widths
+}
+
+func (idx *resourceIndexType) put(ri interface{}) {
+	ns, name := resItemNamespace(ri), resItemName(ri)
+	idx.items[storeKey(ns, name)] = ri
+	if idx.byNamespace[ns] == nil {
+		idx.byNamespace[ns] = map[string]interface{}{}
+	}
+	idx.byNamespace[ns][name] = ri
+}
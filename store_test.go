@@ -0,0 +1,156 @@
+package kubexp
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testItem(ns, name string) map[string]interface{} {
+	return map[string]interface{}{"metadata": map[string]interface{}{"namespace": ns, "name": name}}
+}
+
+func testItemWithUID(ns, name, uid string) map[string]interface{} {
+	ri := testItem(ns, name)
+	ri["metadata"].(map[string]interface{})["uid"] = uid
+	return ri
+}
+
+func Test_StoreUpsertAndDelete(t *testing.T) {
+	require := require.New(t)
+	s := newStore()
+	require.True(s.upsert("pods", testItem("dev", "web")))
+	require.True(s.upsert("pods", testItem("prod", "web")))
+	require.False(s.upsert("pods", testItem("dev", "web")))
+	require.Len(s.list("pods"), 2)
+
+	snapshot, version := s.snapshot("pods", "dev")
+	require.True(s.delete("pods", testItem("dev", "web")))
+	require.False(s.delete("pods", testItem("dev", "web")))
+	require.Len(s.list("pods"), 1)
+	require.Len(snapshot, 1, "snapshot must not change")
+	require.True(s.version("pods") > version)
+	items, _ := s.snapshot("pods", "dev")
+	require.Empty(items)
+	items, _ = s.snapshot("pods", "prod")
+	require.Len(items, 1)
+}
+
+func Test_StoreVersionChangesOnFirstReplace(t *testing.T) {
+	require := require.New(t)
+	s := newStore()
+	_, version := s.snapshot("pods", "*ALL*")
+	s.replace("pods", []interface{}{testItem("dev", "web")})
+	require.NotEqual(version, s.version("pods"))
+}
+
+func Test_StoreIgnoresDeleteOfRecreatedItem(t *testing.T) {
+	require := require.New(t)
+	s := newStore()
+	s.upsert("pods", testItemWithUID("dev", "web", "uid-1"))
+	s.upsert("pods", testItemWithUID("dev", "web", "uid-2"))
+	require.False(s.delete("pods", testItemWithUID("dev", "web", "uid-1")))
+	require.Equal("uid-2", resItemUID(s.get("pods", "dev", "web")))
+	require.True(s.delete("pods", testItemWithUID("dev", "web", "uid-2")))
+	require.Nil(s.get("pods", "dev", "web"))
+}
+
+func Test_ResourceItemsAreSortedOnChangeOnly(t *testing.T) {
+	require := require.New(t)
+	be := newBackend(contextType{Name: "test"})
+	podType := resourceType{Name: "pods", Namespace: true}
+	be.store.upsert("pods", testItem("dev", "b"))
+	be.store.upsert("pods", testItem("dev", "a"))
+	be.store.upsert("pods", testItem("prod", "c"))
+
+	items := be.resourceItems("dev", podType)
+	require.Equal([]string{"a", "b"}, []string{resItemName(items[0]), resItemName(items[1])})
+	again := be.resourceItems("dev", podType)
+	require.True(&items[0] == &again[0], "unchanged items must not be sorted again")
+
+	be.store.upsert("pods", testItem("dev", "0"))
+	items = be.resourceItems("dev", podType)
+	require.Equal("0", resItemName(items[0]))
+	be.setSortOrder("pods", sortOrderType{descending: true})
+	items = be.resourceItems("dev", podType)
+	require.Equal("b", resItemName(items[0]))
+}
+
+func Test_StoreReset(t *testing.T) {
+	require := require.New(t)
+	s := newStore()
+	s.replace("pods", []interface{}{testItem("dev", "web")})
+	version := s.version("pods")
+	s.reset()
+	require.Empty(s.list("pods"))
+	require.True(s.version("pods") > version, "items sorted before the reset must be sorted again")
+}
+
+func Test_StoreConcurrentAccess(t *testing.T) {
+	be := newBackend(contextType{Name: "test"})
+	podType := resourceType{Name: "pods", Namespace: true}
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				ns := fmt.Sprintf("ns-%d", w)
+				be.updateResourceItems("pods", map[string]interface{}{"type": "ADDED", "object": testItem(ns, fmt.Sprintf("pod-%d", i))})
+				if i%3 == 0 {
+					be.updateResourceItems("pods", map[string]interface{}{"type": "DELETED", "object": testItem(ns, fmt.Sprintf("pod-%d", i))})
+				}
+			}
+		}(w)
+	}
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				be.resourceItems("*ALL*", podType)
+				be.resourceItems("ns-1", podType)
+				be.isChanged("ns-1/pods/pod-1")
+				be.updateChangedObjList()
+			}
+		}()
+	}
+	wg.Wait()
+	require.Len(t, be.resourceItems("*ALL*", podType), 4*66)
+	require.Len(t, be.resourceItems("ns-2", podType), 66)
+}
+
+func Test_TemplateCacheConcurrentAccess(t *testing.T) {
+	be := newBackend(contextType{Name: "test"})
+	widgets := resourceType{Name: "widgets", Views: []viewType{{Name: "list", Template: "{{.metadata.name}}"}}}
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			// custom resource definition events forget the templates of the views
+			resourceTpl(widgets, widgets.Views[0])
+			forgetTemplates(widgets)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			be.store.upsert("pods", testItem("dev", fmt.Sprintf("pod-%d", i)))
+			val1(testItem("dev", "pod"), fmt.Sprintf("{{.metadata.name}}-%d", i))
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			var buf bytes.Buffer
+			require.Nil(t, tpl(fmt.Sprintf("concurrent-%d", i), "{{.metadata.namespace}}").Execute(&buf, testItem("dev", "pod")))
+			require.Equal(t, "dev", buf.String())
+		}
+	}()
+	wg.Wait()
+	require.Len(t, be.store.list("pods"), 200)
+}
@@ -0,0 +1,125 @@
+package kubexp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"text/template"
+)
+
+// serverTable lists are rendered by the api server as tables, with the columns kubectl shows, instead of the list views
+var serverTable = false
+
+// tableAccept media type requesting a table instead of a list, lists and watches send it with the tableQuery parameter
+const tableAccept = "application/json;as=Table;g=meta.k8s.io;v=v1"
+
+// tableQuery the rows of a table contain the whole objects, so the detail views can be shown
+const tableQuery = "includeObject=Object"
+
+// widths of the columns of a table, the name column is as wide as in the list views
+const tableNameWidth = 50
+const tableMaxWidth = 30
+
+type tableColumnType struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Format   string `json:"format"`
+	Priority int    `json:"priority"`
+}
+
+type tableRowType struct {
+	Cells  []interface{}          `json:"cells"`
+	Object map[string]interface{} `json:"object"`
+}
+
+// tableType table of the meta.k8s.io api group, sent instead of a list or as object of a watch event
+type tableType struct {
+	ColumnDefinitions []tableColumnType `json:"columnDefinitions"`
+	Rows              []tableRowType    `json:"rows"`
+}
+
+// query of a list or watch url, the separator is '?' or '&' depending on query
+func withTableQuery(query string) string {
+	if !serverTable {
+		return query
+	}
+	if query == "" {
+		return "?" + tableQuery
+	}
+	return query + "&" + tableQuery
+}
+
+// tableHeader the Accept header of lists and watches, nil if tables aren't requested
+func tableHeader() http.Header {
+	if !serverTable {
+		return nil
+	}
+	return http.Header{"Accept": {tableAccept}}
+}
+
+// unwrapTableEvent the object of a watch event is a table with the changed object as row. The cells are stored, the event gets the object of the row
+func (b *backendType) unwrapTableEvent(resKey string, watch map[string]interface{}) map[string]interface{} {
+	obj, ok := watch["object"].(map[string]interface{})
+	if !ok || obj["kind"] != "Table" {
+		return watch
+	}
+	data, err := json.Marshal(obj)
+	if err != nil {
+		errorlog.Printf("can't marshal table of resource %s: %v", resKey, err)
+		return watch
+	}
+	var table tableType
+	if err := json.Unmarshal(data, &table); err != nil {
+		errorlog.Printf("can't unmarshal table of resource %s: %v", resKey, err)
+		return watch
+	}
+	b.store.setTable(resKey, table)
+	if len(table.Rows) == 0 || table.Rows[0].Object == nil {
+		return watch
+	}
+	return map[string]interface{}{"type": watch["type"], "object": table.Rows[0].Object}
+}
+
+// tableTplNames name of the cached table template of each resource, the name contains the widths of the columns
+var tableTplNames = map[string]string{}
+
+// tableTpl list view with the columns of the table of the resource, columns which kubectl shows only in wide output are left out.
+// When the widths change, the previous template of the resource is removed from the cache
+func tableTpl(res resourceType, columns []tableColumnType) *template.Template {
+	widths := backend.store.cellWidths(res.key())
+	tplName := res.key() + "table"
+	tplStr := ""
+	for i, c := range columns {
+		if c.Priority > 0 {
+			continue
+		}
+		width := tableNameWidth
+		if c.Format != "name" {
+			width = len(c.Name)
+			if i < len(widths) && widths[i] > width {
+				width = widths[i]
+			}
+			if width > tableMaxWidth {
+				width = tableMaxWidth
+			}
+		}
+		tplName = fmt.Sprintf("%s/%s:%d", tplName, c.Name, width)
+		tplStr += fmt.Sprintf("{{- header %q . (tableCell . %q %d) | printf \"%%-%d.%ds \" -}}\n", c.Name, res.key(), i, width, width)
+	}
+	templateMutex.Lock()
+	if previous := tableTplNames[res.key()]; previous != tplName {
+		delete(templateCache, previous)
+		tableTplNames[res.key()] = tplName
+	}
+	templateMutex.Unlock()
+	return tpl(tplName, tplStr)
+}
+
+// tableCell cell i of the row of the resource item ri in the table of the resource
+func tableCell(ri interface{}, resKey string, i int) interface{} {
+	cells := backend.store.cells(resKey, resItemNamespace(ri), resItemName(ri))
+	if i < len(cells) {
+		return cells[i]
+	}
+	return ""
+}
@@ -0,0 +1,63 @@
+package kubexp
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ServerTable(t *testing.T) {
+	require := require.New(t)
+	serverTable = true
+	defer func() { serverTable = false }()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/pods" || r.Header.Get("Accept") != tableAccept || r.URL.Query().Get("includeObject") != "Object" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, `{"kind":"Table","apiVersion":"meta.k8s.io/v1","metadata":{"resourceVersion":"10"},
+			"columnDefinitions":[
+				{"name":"Name","type":"string","format":"name","priority":0},
+				{"name":"Ready","type":"string","format":"","priority":0},
+				{"name":"Restarts","type":"integer","format":"","priority":0},
+				{"name":"IP","type":"string","format":"","priority":1}],
+			"rows":[{"cells":["web","1/1",3,"10.0.0.1"],"object":{"kind":"Pod","apiVersion":"v1","metadata":{"name":"web","namespace":"dev"}}}]}`)
+	}))
+	defer server.Close()
+	defer func(be *backendType) { backend = be }(backend)
+	backend = newTestWatchBackend(server)
+
+	w := newResourceWatch(podResource, "")
+	require.Nil(backend.list(w))
+	require.Equal("10", w.getResourceVersion())
+	require.Equal("Pod", backend.store.get("pods", "dev", "web").(map[string]interface{})["kind"])
+	require.Len(backend.store.columns("pods"), 4)
+
+	backend.updateResourceItems("pods", backend.unwrapTableEvent("pods", unmarshall(`{"type":"ADDED","object":{"kind":"Table","apiVersion":"meta.k8s.io/v1",
+		"rows":[{"cells":["database","0/1",12,"10.0.0.2"],"object":{"kind":"Pod","apiVersion":"v1","metadata":{"name":"database","namespace":"dev"}}}]}}`)))
+	require.Len(backend.store.list("pods"), 2)
+	require.Len(backend.store.columns("pods"), 4, "columns are kept when an event has none")
+
+	tpl := resourceListTpl(podResource)
+	render := func(item interface{}) string {
+		buf := new(bytes.Buffer)
+		require.Nil(tpl.Execute(buf, item))
+		return buf.String()
+	}
+	require.Equal(fmt.Sprintf("%-50.50s %-5.5s %-8.8s ", "Name", "Ready", "Restarts"), render(map[string]interface{}{"header": "true"}))
+	require.Equal(fmt.Sprintf("%-50.50s %-5.5s %-8.8s ", "database", "0/1", "12"), render(backend.store.get("pods", "dev", "database")))
+
+	cached := len(templateCache)
+	backend.updateResourceItems("pods", backend.unwrapTableEvent("pods", unmarshall(`{"type":"ADDED","object":{"kind":"Table","apiVersion":"meta.k8s.io/v1",
+		"rows":[{"cells":["cache","1/1",1234567890,"10.0.0.3"],"object":{"kind":"Pod","apiVersion":"v1","metadata":{"name":"cache","namespace":"dev"}}}]}}`)))
+	tpl = resourceListTpl(podResource)
+	require.Equal(fmt.Sprintf("%-50.50s %-5.5s %-10.10s ", "cache", "1/1", "1234567890"), render(backend.store.get("pods", "dev", "cache")))
+	require.Equal(cached, len(templateCache), "the template of the previous widths is removed from the cache")
+
+	backend.updateResourceItems("pods", unmarshall(`{"type":"DELETED","object":{"kind":"Pod","metadata":{"name":"database","namespace":"dev"}}}`))
+	require.Nil(backend.store.cells("pods", "dev", "database"))
+}
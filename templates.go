@@ -8,6 +8,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"text/template"
 
 	"github.com/alitari/gocui"
@@ -18,29 +19,60 @@ var defaultTemplate = `{{.metadata.name | printf "%-50.50s"  }}`
 
 var templateCache = map[string]*template.Template{}
 
+// templateMutex guards the template cache, it is used by the ui and by the watches through val1 and configValue
+var templateMutex sync.RWMutex
+
 func resourceListTpl(res resourceType) *template.Template {
+	if serverTable {
+		if columns := backend.store.columns(res.key()); len(columns) > 0 {
+			return tableTpl(res, columns)
+		}
+	}
 	v := cfg.listView(res)
 	return resourceTpl(res, v)
 }
 
 func resourceTpl(res resourceType, v viewType) *template.Template {
-	return tpl(res.Name+v.Name, v.Template)
+	return tpl(res.key()+v.Name, v.Template)
 }
 
 func tpl(tplName, tplStr string) *template.Template {
-	if templateCache[tplName] == nil {
-		tpl := template.Must(template.New(tplName).Funcs(templateFuncMap).Parse(tplStr))
-		templateCache[tplName] = tpl
+	return cachedTemplate(tplName, func() *template.Template {
+		return template.Must(template.New(tplName).Funcs(templateFuncMap).Parse(tplStr))
+	})
+}
+
+// forgetTemplates removes the templates of the views of the resource from the cache, e.g. when its views were changed
+func forgetTemplates(res resourceType) {
+	templateMutex.Lock()
+	defer templateMutex.Unlock()
+	for _, v := range res.Views {
+		delete(templateCache, res.key()+v.Name)
 	}
-	return templateCache[tplName]
 }
 
 func tplNoFunc(tplName, tplStr string) *template.Template {
-	if templateCache[tplName] == nil {
-		tpl := template.Must(template.New(tplName).Parse(tplStr))
-		templateCache[tplName] = tpl
+	return cachedTemplate(tplName, func() *template.Template {
+		return template.Must(template.New(tplName).Parse(tplStr))
+	})
+}
+
+// cachedTemplate the template of the cache, it is parsed and cached if it isn't there yet
+func cachedTemplate(tplName string, parse func() *template.Template) *template.Template {
+	templateMutex.RLock()
+	t := templateCache[tplName]
+	templateMutex.RUnlock()
+	if t != nil {
+		return t
 	}
-	return templateCache[tplName]
+	t = parse()
+	templateMutex.Lock()
+	defer templateMutex.Unlock()
+	if cached := templateCache[tplName]; cached != nil {
+		return cached
+	}
+	templateCache[tplName] = t
+	return t
 }
 
 var templateFuncMap = template.FuncMap{
@@ -68,6 +100,8 @@ var templateFuncMap = template.FuncMap{
 	"podStatus":             podStatus,
 	"portForwardPortsShort": portForwardPortsShort,
 	"portForwardPortsLong":  portForwardPortsLong,
+	"jsonPath":              jsonPath,
+	"tableCell":             tableCell,
 
 	"green":    colorGreen,
 	"greenEmp": colorGreenInverse,
@@ -97,13 +131,22 @@ var templateFuncMap = template.FuncMap{
 	"contextColor":     colorContext,
 	"contextColorEmp":  colorContextEmp,
 	"colorPhase":       colorPhase,
+	"colorHealth":      colorHealth,
 	"blinkWhenChanged": blinkWhenChanged,
 }
 
 func header(header string, rootVal, val interface{}) interface{} {
 	switch rootVal.(type) {
 	case map[string]interface{}:
-		if rootVal.(map[string]interface{})["header"] == "true" {
+		root := rootVal.(map[string]interface{})
+		if root["header"] == "true" {
+			// the header row of a list shows the sort column with its order
+			if root["sortColumn"] == header {
+				if root["sortDescending"] == true {
+					return header + "↓"
+				}
+				return header + "↑"
+			}
 			return header
 		}
 	}
@@ -130,8 +173,7 @@ func decode64(it interface{}) string {
 }
 
 func podLog() interface{} {
-	//infolog.Printf("-->logs: %s", string(backend.podLogs))
-	return string(backend.podLogs)
+	return backend.podLog()
 }
 
 func podsForNode(nodeName string) interface{} {
@@ -331,6 +373,17 @@ func colorPhase(text string) string {
 	return text
 }
 
+func colorHealth(status string) string {
+	text := fmt.Sprintf("%-11.11s", status)
+	switch status {
+	case contextReachable:
+		return colorGreenEmp(text)
+	case contextChecking:
+		return colorYellowEmp(text)
+	}
+	return colorRedEmp(text)
+}
+
 func colorGrey(text string) string {
 	return colorizeText(text, 0, len(text), greyInlineColor)
 }
@@ -642,8 +695,8 @@ func keyString(keyEvent interface{}) string {
 			keyStr = "Ctrl-d"
 		case gocui.KeyCtrl2:
 			keyStr = "Ctrl-2"
-		case gocui.KeyCtrl3:
-			keyStr = "Ctrl-3"
+		case gocui.KeyEsc:
+			keyStr = "Esc"
 		case gocui.KeySpace:
 			keyStr = "Space"
 		case gocui.KeyPgdn:
@@ -652,6 +705,10 @@ func keyString(keyEvent interface{}) string {
 			keyStr = "Page Up"
 		case gocui.KeyCtrlO:
 			keyStr = "Ctrl-o"
+		case gocui.KeyCtrlR:
+			keyStr = "Ctrl-r"
+		case gocui.KeyInsert:
+			keyStr = "Insert"
 		case gocui.KeyDelete:
 			if m == gocui.ModAlt {
 				keyStr = "Alt-Delete"
@@ -707,6 +764,14 @@ func resItemContainers(ri interface{}) []string {
 	return strings.Split(contStr[:len(contStr)-1], ",")
 }
 
+func resItemUID(ri interface{}) string {
+	return configValue(ri, "{{ .metadata.uid }}")
+}
+
+func resItemResourceVersion(ri interface{}) string {
+	return val1(ri, "{{ .metadata.resourceVersion }}")
+}
+
 func resItemCreationTimestamp(ri interface{}) string {
 	return val1(ri, "{{ .metadata.creationTimestamp }}")
 }
@@ -755,12 +820,11 @@ func printMap(it interface{}) string {
 
 func isObjInChangedList(it interface{}, resName string) bool {
 	id := fmt.Sprintf("%s/%s/%s", resItemNamespace(it), resName, resItemName(it))
-	res := backend.changedObjSet[id]
-	return res
+	return backend.isChanged(id)
 }
 
 func blinkWhenChanged(it interface{}, resName string, text string) string {
-	if isObjInChangedList(it, resName) && backend.blink {
+	if isObjInChangedList(it, resName) && backend.blinkOn() {
 		return strings.Repeat(" ", len(text))
 	}
 	return text
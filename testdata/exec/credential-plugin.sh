@@ -0,0 +1,13 @@
+#!/bin/sh
+# fake client-go credential plugin, every call is recorded in $CALLS_FILE
+echo "$KUBERNETES_EXEC_INFO" >> "$CALLS_FILE"
+cat <<CRED
+{
+  "apiVersion": "client.authentication.k8s.io/v1beta1",
+  "kind": "ExecCredential",
+  "status": {
+    "token": "$TEST_TOKEN",
+    "expirationTimestamp": "$TEST_EXPIRY"
+  }
+}
+CRED
@@ -2,7 +2,6 @@ package kubexp
 
 import (
 	"bytes"
-	"encoding/base64"
 	"flag"
 	"fmt"
 	"io/ioutil"
@@ -72,8 +71,16 @@ var delResourceFooter = "*DELETE*=delete resource"
 var podsFooter = "*u*=upload *d*=download *1-6*=exec container *p*=port forward"
 var scaleFooter = "*+*,*-*=scale up/down"
 var changeContainerFooter = "*Ctrl-o*=change container"
-var reloadFooter = "*SPACE*=reload"
+var reloadFooter = "*Ctrl-r*=reload"
+var checkContextFooter = "*r*=check health"
 var helpFooter = "*h*=help"
+var selectorFooter = "*l*=selector"
+var quickFilterFooter = "*/*=filter"
+var sortFooter = "*s*=sort column *S*=reverse"
+var markFooter = "*SPACE*=mark *L*=label"
+var restartFooter = "*R*=restart"
+var editFooter = "*e*=edit"
+var createFooter = "*A*=create from manifest"
 
 var currentState stateType
 
@@ -93,13 +100,17 @@ var initState = stateType{
 	},
 	exitFunc: func(toState stateType) {
 		clusterList.widget.items = toIfc(cfg.contexts)
+		clusterList.widget.selectItem(cfg.currentContext)
 		// contextColor := strToColor(cfg.contexts[0].color)
 		// g.FrameFgColor = contextColor
 		// g.FrameBgColor = gocui.ColorBlack
 
+		// the contexts are listed right away, their health is filled in in the background
+		cfg.probeStartContext()
+		cfg.discoverResources(backend)
 		err := backend.createWatches(cfg.resources)
 		if err != nil {
-			errorlog.Panicf("Can't connect to api server. url:%s, error: %v", backend.context.Cluster.URL, err)
+			showError(fmt.Sprintf("Can't connect to api server, url:%s ", backend.context.Cluster.URL), err)
 		}
 		resourceMenu.widget.items = resources()
 		clusterRes := clusterRes()
@@ -110,6 +121,8 @@ var initState = stateType{
 var selectNsState = stateType{
 	name: "selectNsState",
 	enterFunc: func(fromState stateType) {
+		// the user takes over, watch events must not change the selection anymore
+		preferredNamespace = ""
 		namespaceList.widget.focus = true
 		namespaceList.widget.footer = setSelectionFooter + " " + listSelectFooter
 	},
@@ -122,7 +135,7 @@ var selectContextState = stateType{
 	name: "selectContextState",
 	enterFunc: func(fromState stateType) {
 		clusterList.widget.focus = true
-		clusterList.widget.footer = setSelectionFooter + " " + listSelectFooter + " " + exitFooter
+		clusterList.widget.footer = setSelectionFooter + " " + checkContextFooter + " " + listSelectFooter + " " + exitFooter
 	},
 	exitFunc: func(toState stateType) {
 		clusterList.widget.focus = false
@@ -135,9 +148,8 @@ var browseState = stateType{
 		if fromState.name == initState.name {
 			ns := selectedNamespace()
 			selRes := selectedResource()
-			updateResourceItemsListTitle(selRes.Name)
-			ris := backend.resourceItems(ns, selRes)
-			resourceItemsList.widget.items = ris
+			updateResourceItemsListTitle(selRes)
+			resourceItemsList.widget.items = listResourceItems(ns, selRes)
 			resourceItemsList.widget.template = resourceListTpl(selRes)
 		}
 		resourceMenu.widget.visible = true
@@ -225,6 +237,19 @@ var execPodState = stateType{
 	},
 }
 
+var manifestFileState = stateType{
+	name: "manifestFileState",
+	enterFunc: func(fromState stateType) {
+		fileList.widget.visible = true
+		fileList.widget.focus = true
+		fileList.widget.footer = setFileSelectionFooter + " " + listSelectFooter + " " + exitFooter
+	},
+	exitFunc: func(fromState stateType) {
+		fileList.widget.visible = false
+		fileList.widget.focus = false
+	},
+}
+
 var errorState = stateType{
 	name: "errorState",
 	enterFunc: func(fromState stateType) {
@@ -251,6 +276,67 @@ var confirmState = stateType{
 	},
 }
 
+var selectorState = stateType{
+	name: "selectorState",
+	enterFunc: func(fromState stateType) {
+		res := selectedResource()
+		selectorWidget.show(fmt.Sprintf("Selector for %s, e.g. app=web,tier!=cache,status.phase=Running  *RETURN*=set *ESC*=cancel", res.Name), backend.selector(res.key()).String())
+	},
+	exitFunc: func(toState stateType) {
+		selectorWidget.active = false
+		selectorWidget.visible = false
+	},
+}
+
+var quickFilterState = stateType{
+	name: "quickFilterState",
+	enterFunc: func(fromState stateType) {
+		// the list stays visible, it is narrowed while typing
+		resourceMenu.widget.visible = true
+		resourceItemsList.widget.visible = true
+		quickFilterWidget.show("Filter by name, namespace, labels and columns  *↑*,*↓*=select *RETURN*=keep *ESC*=clear", quickFilter)
+	},
+	exitFunc: func(toState stateType) {
+		quickFilterWidget.active = false
+		quickFilterWidget.visible = false
+	},
+}
+
+var labelState = stateType{
+	name: "labelState",
+	enterFunc: func(fromState stateType) {
+		labelWidget.show(fmt.Sprintf("Labels of %s, e.g. app=web,tier-  to set app and remove tier  *RETURN*=set *ESC*=cancel", bulkTargetsText()), "")
+	},
+	exitFunc: func(toState stateType) {
+		labelWidget.active = false
+		labelWidget.visible = false
+	},
+}
+
+var bulkResultState = stateType{
+	name: "bulkResultState",
+	enterFunc: func(fromState stateType) {
+		bulkResultWidget.active = true
+		bulkResultWidget.visible = true
+	},
+	exitFunc: func(toState stateType) {
+		bulkResultWidget.active = false
+		bulkResultWidget.visible = false
+	},
+}
+
+var previewState = stateType{
+	name: "previewState",
+	enterFunc: func(fromState stateType) {
+		previewWidget.active = true
+		previewWidget.visible = true
+	},
+	exitFunc: func(toState stateType) {
+		previewWidget.active = false
+		previewWidget.visible = false
+	},
+}
+
 var loadingState = stateType{
 	name: "loadingState",
 	enterFunc: func(fromState stateType) {
@@ -290,9 +376,17 @@ var execWidget *shellWidget
 var errorWidget *textWidget
 var confirmWidget *textWidget
 var loadingWidget *textWidget
+var selectorWidget *promptWidget
+var quickFilterWidget *promptWidget
+var labelWidget *promptWidget
+var bulkResultWidget *textWidget
+var previewWidget *textWidget
 var fileList *nlist
 
 var selectedResourceCategoryIndex = 0
+
+// namespace to select as soon as it appears in the namespace list
+var preferredNamespace string
 var selectedClusterInfoIndex = 0
 
 var maxX, maxY int
@@ -318,6 +412,9 @@ var exe = make(chan *exec.Cmd)
 
 var keyBindings = []keyBindingType{}
 
+// mutationKeyEvents key events of the commands, which change the cluster. They aren't bound in read-only contexts
+var mutationKeyEvents = map[keyEventType]bool{}
+
 // Run entrypoint of the program
 func Run() {
 	parseFlags()
@@ -338,9 +435,10 @@ func Run() {
 	}
 	infolog.Printf("-------------------------------------< Startup >---------------------------------------------\n")
 	cfg = newConfig(*configFile)
-	ctx := cfg.contexts[0]
+	ctx := cfg.contexts[cfg.currentContext]
 	resourceCategories = cfg.allResourceCategories()
 	backend = newBackend(ctx)
+	preferredNamespace = ctx.defaultNamespace()
 	go commandRunner()
 	currentState = initState
 
@@ -367,7 +465,16 @@ func commandRunner() {
 			cmd.Stdin = os.Stdin
 			cmd.Stderr = os.Stderr
 			cmd.Stdout = os.Stdout
-			cmd.Run()
+			err := cmd.Run()
+			if e := pendingEdit; e != nil && e.cmd == cmd {
+				if err != nil {
+					// e.g. ':cq' in vi leaves the editor without saving
+					warninglog.Printf("editor exited with error, edit is discarded: %v", err)
+					e.discarded = true
+				} else {
+					e.prepare(backend)
+				}
+			}
 			wg.Done()
 		}
 	}
@@ -382,7 +489,7 @@ func initGui() {
 	if currentState.name != browseState.name {
 		createWidgets()
 	}
-	g.SetManager(clusterList.widget, clusterResourcesWidget, namespaceList.widget, resourceMenu.widget, resourcesItemDetailsMenu.widget, searchmodeWidget, resourceItemsList.widget, resourceItemDetailsWidget, helpWidget, errorWidget, execWidget, confirmWidget, loadingWidget, fileList.widget)
+	g.SetManager(clusterList.widget, clusterResourcesWidget, namespaceList.widget, resourceMenu.widget, resourcesItemDetailsMenu.widget, searchmodeWidget, resourceItemsList.widget, resourceItemDetailsWidget, helpWidget, errorWidget, execWidget, confirmWidget, loadingWidget, fileList.widget, selectorWidget, quickFilterWidget, labelWidget, bulkResultWidget, previewWidget)
 
 	bindKeys()
 	if currentState.name != browseState.name {
@@ -399,6 +506,9 @@ func initGui() {
 	contextColor := strToColor(ctx.color)
 	g.FrameFgColor = contextColor
 	g.FrameBgColor = gocui.ColorBlack
+	if pendingEdit != nil {
+		finishEdit()
+	}
 
 	if err := g.MainLoop(); err != nil && err != gocui.ErrQuit {
 		errorlog.Printf("error in mail loop: %v", err)
@@ -406,12 +516,22 @@ func initGui() {
 }
 
 func parseFlags() {
-	configFile = flag.String("config", filepath.Join(homeDir(), ".kube", "config"), "absolute path to the config file")
+	configFile = flag.String("config", defaultConfigFile(), "absolute path to the config file, several files are merged like with the KUBECONFIG environment variable")
+	startContext = flag.String("context", "", "context to start with, defaults to the current-context of the config file")
+	startNamespace = flag.String("namespace", "", "namespace to select when a context is loaded, defaults to the namespace of the context")
+	watchNamespaces = flag.String("watchNamespaces", "", "comma separated namespaces to watch when watching a resource cluster wide is forbidden, defaults to all namespaces or the namespace of the context")
+	serviceAccount = flag.String("serviceAccount", "", "request tokens for this service account ('<namespace>/<name>') with the TokenRequest api instead of using the kubeconfig credentials directly")
 	logLevel = flag.String("logLevel", "info", "verbosity of log output. Values: 'trace','info','warn','error'")
 	logFilePath = flag.String("logFile", "./kubexp.log", "fullpath to log file, set empty ( -logFile='') if no logfile should be used")
 	flag.IntVar(&portforwardStartPort, "portForwardStartPort", 32100, "start of portforward range")
 	flag.IntVar(&restCallTimeout, "restCallTimeout", 3, "time out for rest calls in seconds")
 	flag.IntVar(&kubeCtlTimeout, "kubectlTimeout", 5, "time out for kubectl calls in seconds")
+	flag.IntVar(&watchTTL, "watchTTL", 300, "seconds after which the watch of a resource, which isn't shown, is stopped")
+	flag.IntVar(&listChunkSize, "listChunkSize", 500, "resources are listed in chunks of this many items")
+	flag.BoolVar(&serverSelector, "serverSelector", false, "send the selectors to the api server, so only the selected items are cached")
+	flag.BoolVar(&serverTable, "serverTable", false, "show lists with the columns rendered by the api server, like kubectl, instead of the list views")
+	flag.BoolVar(&readOnlyMode, "readonly", false, "read-only mode: deleting, scaling, editing and creating resources and kubectl exec, cp and port-forward are disabled in every context")
+	policyFile = flag.String("policyFile", defaultPolicyFile(), "kubexp config file with the policies of the contexts, e.g. 'policies: { prod-*: readonly, staging: confirm-twice }'")
 
 	flag.IntVar(&clusterLivenessPeriod, "clusterLivenessPeriod", 5, "cluster liveness check period in seconds")
 
@@ -466,24 +586,105 @@ func updateResourceItemList(reset bool) {
 			newResource()
 		} else {
 			res := selectedResource()
-			resourceItemsList.widget.items = backend.resourceItems(selectedNamespace(), res)
-			updateResourceItemsListTitle(res.Name)
+			resourceItemsList.widget.items = listResourceItems(selectedNamespace(), res)
+			updateResourceItemsListTitle(res)
+			showListProgress(res)
 		}
 		return nil
 	})
 }
 
+// showListProgress shows the items fetched so far in the loading widget while the resource is listed, the ui isn't blocked meanwhile
+func showListProgress(res resourceType) {
+	fetched, total := backend.listProgress(res.key())
+	if fetched == 0 {
+		if loadingWidget.visible && currentState.name != loadingState.name {
+			loadingWidget.visible = false
+		}
+		return
+	}
+	mess := fmt.Sprintf("Loading %s: %d items...", res.Name, fetched)
+	if total > 0 {
+		mess = fmt.Sprintf("Loading %s: %d of %d items...", res.Name, fetched, total)
+	}
+	loadingWidget.setContent([]interface{}{mess}, tpl("loading", loadingTemplate))
+	loadingWidget.visible = true
+}
+
 func updateNamespaces() {
+	if g == nil {
+		return
+	}
 	tracelog.Printf("update namespace")
 	g.Update(func(gui *gocui.Gui) error {
-		nsType := cfg.resourcesOfName("namespaces")
-		ris := backend.resourceItems("", nsType)
-		namespaceList.widget.items = []interface{}{namespaceALL}
-		namespaceList.widget.items = append(namespaceList.widget.items, ris...)
+		setNamespaceItems()
 		return nil
 	})
 }
 
+func updateCustomResources() {
+	if g == nil {
+		return
+	}
+	tracelog.Printf("update custom resources")
+	g.Update(func(gui *gocui.Gui) error {
+		setCustomResources()
+		return nil
+	})
+}
+
+// setCustomResources applies the watched custom resource definitions to the resources, the menu keeps the selected resource
+func setCustomResources() {
+	cfg.applyCustomResources(backend.store.list(crdKey))
+	for _, res := range cfg.resourcesOfCategory("custom") {
+		forgetTemplates(res)
+	}
+	if resourceCategories[selectedResourceCategoryIndex] != "custom" || len(resourceMenu.widget.items) == 0 {
+		return
+	}
+	selRes := selectedResource()
+	resourceMenu.widget.items = resources()
+	resourceMenu.widget.selectedItem = 0
+	for i, res := range resourceMenu.widget.items {
+		if res.(resourceType).key() == selRes.key() {
+			resourceMenu.widget.selectedItem = i
+		}
+	}
+	if len(resourceMenu.widget.items) == 0 {
+		findResourceCategoryWithResources(1)
+		return
+	}
+	if res := selectedResource(); res.key() != selRes.key() {
+		newResource()
+	} else {
+		resourceItemsList.widget.template = resourceListTpl(res)
+	}
+}
+
+// setNamespaceItems keeps the selected namespace, or selects the preferred namespace as soon as it is known
+func setNamespaceItems() {
+	selNs := preferredNamespace
+	if selNs == "" {
+		selNs = selectedNamespace()
+	}
+	nsType := cfg.resourcesOfName("namespaces")
+	ris := backend.resourceItems("", nsType)
+	if backend.watchState(nsType.key()) == watchForbidden {
+		ris = []interface{}{}
+		for _, ns := range backend.fallbackNamespaces() {
+			ris = append(ris, map[string]interface{}{"metadata": map[string]interface{}{"name": ns}})
+		}
+	}
+	namespaceList.widget.items = append([]interface{}{namespaceALL}, ris...)
+	selIndex := 0
+	for i, ns := range namespaceList.widget.items {
+		if resItemName(ns) == selNs {
+			selIndex = i
+		}
+	}
+	namespaceList.widget.selectItem(selIndex)
+}
+
 func createWidgets() {
 	maxX, maxY = g.Size()
 	sepXAt := int(float64(maxX) * 0.75)
@@ -499,7 +700,7 @@ func createWidgets() {
 	clusterList.widget.title = "Cluster"
 	clusterList.widget.visible = true
 	clusterList.widget.frame = true
-	clusterList.widget.template = tpl("clusterTemplate", `{{ "Name:" | contextColorEmp }} {{ .Name | printf "%-20.20s" }}  {{ "URL:" | contextColorEmp }} {{ .Cluster.URL }}`)
+	clusterList.widget.template = tpl("clusterTemplate", `{{ "Name:" | contextColorEmp }} {{ .Name | printf "%-20.20s" }} {{ .Status | colorHealth }}  {{ "URL:" | contextColorEmp }} {{ .Cluster.URL }}  {{ "Auth:" | contextColorEmp }} {{ .AuthStrategy }}`)
 	clusterResourcesWidget = newTextWidget("clusterResources", "cluster resources", true, false, sepXAt2+2, 1, sepXAt-sepXAt2-1, 2)
 
 	namespaceList = newNlist("namespaces", sepXAt+2, 1, maxX-sepXAt-3, 10)
@@ -523,6 +724,10 @@ func createWidgets() {
 
 	//resourceRenderer
 	searchmodeWidget = newSearchWidget("search", "search", false, sepXAt+2, 4, maxX-sepXAt-3)
+	selectorWidget = newPromptWidget("selector", 10, sepYAt+1, maxX-20)
+	quickFilterWidget = newPromptWidget("quickFilter", 10, maxY-4, maxX-20)
+	quickFilterWidget.changed = setQuickFilter
+	labelWidget = newPromptWidget("label", 10, sepYAt+1, maxX-20)
 
 	resourceItemsList = newNlist("resourceItems", 1, sepYAt, maxX-2, maxY-sepYAt-1)
 	resourceItemsList.widget.visible = true
@@ -530,6 +735,11 @@ func createWidgets() {
 	resourceItemsList.widget.frame = true
 	resourceItemsList.widget.headerItem = map[string]interface{}{"header": "true"}
 	resourceItemsList.widget.headerFgColor = gocui.ColorDefault | gocui.AttrBold
+	resourceItemsList.widget.highlightFunc = func(row string) string {
+		return highlightMatches(row, quickFilter)
+	}
+	resourceItemsList.widget.markFunc = isMarked
+	resourceItemsList.widget.markFgColor = gocui.ColorCyan | gocui.AttrBold
 
 	resourceItemDetailsWidget = newTextWidget("text", "resource item details", false, true, 1, sepYAt, maxX-2, maxY-sepYAt-1)
 
@@ -542,6 +752,11 @@ func createWidgets() {
 
 	confirmWidget = newTextWidget("confirm", "Confirm", false, false, 20, 7, maxX-40, 4)
 
+	bulkResultWidget = newTextWidget("bulkResult", "Result", false, false, 10, 5, maxX-20, 4)
+	bulkResultWidget.footer = "*RETURN*=back to list"
+	previewWidget = newTextWidget("preview", "Preview (dry run)", false, false, 5, 3, maxX-10, maxY-6)
+	previewWidget.footer = "*y*=yes *n*=no " + scrollLineFooter + " " + scrollPageFooter
+
 	loadingWidget = newTextWidget("loading", "", false, false, 30, 10, maxX-60, 4)
 
 	fileList = newNlist("files", maxX/2-45, 5, 90, maxY-10)
@@ -558,10 +773,16 @@ func createWidgets() {
 }
 
 func updateResourceItemsListFooter() {
-	resourceItemsList.widget.footer = delResourceFooter + " " + listSelectFooter + " " + detailsViewFooter + " " + reloadFooter + " " + helpFooter + " " + exitFooter
+	resourceItemsList.widget.footer = listSelectFooter + " " + detailsViewFooter + " " + selectorFooter + " " + quickFilterFooter + " " + sortFooter + " " + reloadFooter + " " + helpFooter + " " + exitFooter
+	if !backend.context.readOnly() {
+		resourceItemsList.widget.footer = delResourceFooter + " " + editFooter + " " + createFooter + " " + markFooter + " " + resourceItemsList.widget.footer
+	}
 	if resourceItemsList.widget.pc() > 1 {
 		resourceItemsList.widget.footer = pageSelectFooter + " " + resourceItemsList.widget.footer
 	}
+	if backend.context.readOnly() {
+		return
+	}
 	switch selectedResource().Name {
 	case "pods":
 		resourceItemsList.widget.footer = podsFooter + " " + resourceItemsList.widget.footer
@@ -576,6 +797,9 @@ func updateResourceItemsListFooter() {
 	case "statefulsets":
 		resourceItemsList.widget.footer = scaleFooter + " " + resourceItemsList.widget.footer
 	}
+	if isRestartable(selectedResource()) {
+		resourceItemsList.widget.footer = restartFooter + " " + resourceItemsList.widget.footer
+	}
 }
 
 func resourceItemDetailsViews() []interface{} {
@@ -591,9 +815,7 @@ func filterResources(res []resourceType) []interface{} {
 	ret := make([]interface{}, 0)
 	for _, r := range res {
 		ns := selectedNamespace()
-		resItems := backend.resourceItems(ns, r)
-
-		if len(resItems) > 0 {
+		if !backend.isEmpty(ns, r) {
 			ret = append(ret, r)
 		} else {
 			tracelog.Printf("no resourceItems for %s", r.Name)
@@ -612,9 +834,43 @@ func showError(mess string, err error) {
 	})
 }
 
+// setSelector filters the items of the selected resource with the label and field selector text
+func setSelector(text string) {
+	text = strings.TrimSpace(text)
+	sel, err := parseSelector(text)
+	if err != nil {
+		showError(fmt.Sprintf("Invalid selector '%s'", text), err)
+		return
+	}
+	backend.setSelector(selectedResource().key(), sel)
+	setState(browseState)
+	newResource()
+}
+
+// listResourceItems the items of the resource in the namespace, narrowed by the quick filter
+func listResourceItems(ns string, res resourceType) []interface{} {
+	items := backend.resourceItems(ns, res)
+	if strings.TrimSpace(quickFilter) == "" {
+		return items
+	}
+	return cachedQuickFilterItems(items, quickFilter, resourceListTpl(res))
+}
+
+// setQuickFilter narrows the resource item list while the filter text is typed
+func setQuickFilter(text string) {
+	quickFilter = strings.TrimSpace(text)
+	res := selectedResource()
+	resourceItemsList.widget.items = listResourceItems(selectedNamespace(), res)
+	resourceItemsList.widget.selectItem(0)
+	updateResourceItemsListTitle(res)
+	updateResourceItemsListFooter()
+}
+
 func showConfirm(mess string, command commandType) {
 	confirmCommand = command
 	g.Update(func(gui *gocui.Gui) error {
+		// a bulk action lists all affected items
+		confirmWidget.h = min(strings.Count(mess, "\n")+4, maxY-10)
 		co := []interface{}{mess}
 		confirmWidget.setContent(co, tpl("confirm", confirmTemplate))
 		setState(confirmState)
@@ -666,10 +922,9 @@ func newResourceCategory() {
 
 	selRes := selectedResource()
 	ns := selectedNamespace()
-	resItems := backend.resourceItems(ns, selRes)
-
-	resourceItemsList.widget.items = resItems
-	updateResourceItemsListTitle(selRes.Name)
+	resetMarks(backend.context.Name, ns, selRes.key())
+	resourceItemsList.widget.items = listResourceItems(ns, selRes)
+	updateResourceItemsListTitle(selRes)
 	updateResourceItemsListFooter()
 	resourceItemsList.widget.template = resourceListTpl(selRes)
 	if resourceItemsList.widget.selectedItem >= len(resourceItemsList.widget.items) {
@@ -679,59 +934,60 @@ func newResourceCategory() {
 
 func newContext() error {
 	ctx := cfg.contexts[clusterList.widget.selectedItem]
+	if ctx.Status() != contextReachable {
+		if err := ctx.probe(); err != nil {
+			selectBackendContext()
+			showError(fmt.Sprintf("Context '%s' is %s, url:%s ", ctx.Name, ctx.Status(), ctx.Cluster.URL), err)
+			return err
+		}
+	}
 	backend.closeWatches()
+	// the items of the context aren't the marked ones, even if they have the same namespace and name
+	clearMarks()
+	sortOrders := backend.sortOrders
 	backend = newBackend(ctx)
+	// the sort preferences of the resources are kept
+	backend.sortOrders = sortOrders
+	// the key bindings are changed after the current key event is handled
+	g.Update(func(gui *gocui.Gui) error {
+		updateMutationKeys()
+		return nil
+	})
 
 	contextColor := strToColor(ctx.color)
 	g.FrameFgColor = contextColor
 	g.FrameBgColor = gocui.ColorBlack
 
+	cfg.discoverResources(backend)
 	err := backend.createWatches(cfg.resources)
 	if err != nil {
 		showError(fmt.Sprintf("Can't connect to api server, url:%s ", backend.context.Cluster.URL), err)
 	}
-	res := cfg.resourcesOfName("namespaces")
-	resItems := backend.resourceItems("", res)
-
-	namespaceList.widget.items = resItems
+	preferredNamespace = ctx.defaultNamespace()
 	namespaceList.widget.selectedItem = 0
+	setNamespaceItems()
 	clusterRes := clusterRes()
 	clusterResourcesWidget.setContent(&clusterRes, tpl("clusterResources", clusterResourcesTemplate))
 	findResourceCategoryWithResources(1)
 	return nil
 }
 
-func retrieveContextToken(ctx *contextType) error {
-	cmd := kubectl(ctx.Name, "get", "secret")
-	out, errorStr, err := runCmd(cmd)
-	if err != nil {
-		return fmt.Errorf("Error running command '%v': %s\n Details: %s", cmd.Args, err.Error(), errorStr)
-	}
-	var tokenSecretName string
-	for _, l := range strings.Split(string(out), "\n") {
-		name := strings.Split(l, " ")[0]
-		if strings.HasPrefix(name, "default-token") {
-			tokenSecretName = name
-			break
+// selectBackendContext selects the context of the backend in the cluster list
+func selectBackendContext() {
+	for i, ct := range cfg.contexts {
+		if ct.Name == backend.context.Name {
+			clusterList.widget.selectItem(i)
 		}
 	}
+}
 
-	cmd = kubectl(ctx.Name, "get", "secret", tokenSecretName, "-o", "json")
-	out, errorStr, err = runCmd(cmd)
-	if err != nil {
-		return fmt.Errorf("Error running command '%v': %v\n Details: %s", cmd.Args, err, errorStr)
-	}
-
-	tokenSecret := unmarshall(out)
-	tokenB64 := val1(tokenSecret, "{{.data.token}}")
-	token, err := base64.StdEncoding.DecodeString(tokenB64)
-	if err != nil {
-		return err
+// refreshClusterList redraws the cluster list, e.g. when the health of a context has changed
+func refreshClusterList() {
+	if g != nil {
+		g.Update(func(gui *gocui.Gui) error {
+			return nil
+		})
 	}
-	tokenStr := string(token)
-	tracelog.Printf(" retrieved token for context '%s'...", ctx.Name)
-	ctx.user.token = tokenStr
-	return nil
 }
 
 func startFiletransfer(isUpload bool) {
@@ -753,15 +1009,20 @@ func transferFile(destPath string) {
 
 	var cmd *exec.Cmd
 	var mess string
+	var err error
 
 	if fileBrowser.local {
 		absPath, _ := filepath.Abs(destPath)
-		cmd = kubectl(backend.context.Name, "-n", ns, "cp", "-c", con, podName+":"+sourceFile, absPath)
+		cmd, err = backend.kubectl("cp", ns, "-c", con, podName+":"+sourceFile, absPath)
 		mess = fmt.Sprintf("file download:'%s'\n in pod '%s' from namespace '%s'\n to local dir '%s' ", sourceFile, podName, ns, destPath)
 	} else {
-		cmd = kubectl(backend.context.Name, "-n", ns, "cp", "-c", con, sourceFile, podName+":"+path.Join(destPath, path.Base(sourceFile)))
+		cmd, err = backend.kubectl("cp", ns, "-c", con, sourceFile, podName+":"+path.Join(destPath, path.Base(sourceFile)))
 		mess = fmt.Sprintf("file upload:'%s' \n to '%s' in pod '%s' in namespace '%s'", sourceFile, destPath, podName, ns)
 	}
+	if err != nil {
+		showError(fmt.Sprintf("Can't start %s", mess), err)
+		return
+	}
 
 	co := []interface{}{mess + "..."}
 	loadingWidget.setContent(co, tpl("loading", loadingTemplate))
@@ -795,6 +1056,8 @@ func strToColor(colorStr string) gocui.Attribute {
 		return gocui.ColorWhite
 	case "Cyan":
 		return gocui.ColorCyan
+	case "Red":
+		return gocui.ColorRed
 	}
 	return gocui.ColorDefault
 }
@@ -824,70 +1087,304 @@ func strToInlineColor(colorStr string, emp bool) inlineColorType {
 			return cyanEmpInlineColor
 		}
 		return cyanInlineColor
+
+	case "Red":
+		if emp {
+			return redEmpInlineColor
+		}
+		return redInlineColor
 	}
 	return whiteInlineColor
 }
 
 func nameSorting() {
-	if backend.sorter.getName() == "NameSorter" {
-		backend.sorter.setAscending(!backend.sorter.getAscending())
-	} else {
-		backend.sorter = &nameSorterType{ascending: true}
-	}
+	columnSorting("Name")
 }
 
 func ageSorting() {
-	if backend.sorter.getName() == "AgeSorter" {
-		backend.sorter.setAscending(!backend.sorter.getAscending())
+	columnSorting("Age")
+}
+
+// columnSorting sorts the items of the selected resource by the column, the order is reversed when they are already sorted by it
+func columnSorting(column string) {
+	res := selectedResource()
+	order := backend.sortOrder(res.key())
+	if order.sortColumn() == column {
+		order.descending = !order.descending
 	} else {
-		backend.sorter = &timeSorterType{ascending: true}
+		order = sortOrderType{column: column, listTpl: resourceListTpl(res)}
 	}
+	backend.setSortOrder(res.key(), order)
 }
 
-func deleteResource(noGracePeriod bool) {
-	ns := selectedResourceItemNamespace()
+// nextSortColumn sorts the items of the selected resource by the column right of the current sort column
+func nextSortColumn() {
 	res := selectedResource()
-	rname := selectedResourceItemName()
-	_, err := backend.delete(ns, res, rname, noGracePeriod)
+	listTpl := resourceListTpl(res)
+	columns := listColumns(listTpl)
+	if len(columns) == 0 {
+		return
+	}
+	next := 0
+	current := backend.sortOrder(res.key()).sortColumn()
+	for i, c := range columns {
+		if c == current {
+			next = (i + 1) % len(columns)
+		}
+	}
+	backend.setSortOrder(res.key(), sortOrderType{column: columns[next], listTpl: listTpl})
+}
+
+func reverseSortOrder() {
+	res := selectedResource()
+	order := backend.sortOrder(res.key())
+	order.descending = !order.descending
+	backend.setSortOrder(res.key(), order)
+}
+
+// selectedBulkTargets the marked items of the selected resource, the selected item if none is marked
+func selectedBulkTargets() []bulkTargetType {
+	var selected interface{}
+	if len(resourceItemsList.widget.items) > 0 {
+		selected = resourceItemsList.widget.items[resourceItemsList.widget.selectedItem]
+	}
+	return bulkTargets(backend.resourceItems(selectedNamespace(), selectedResource()), selected)
+}
+
+func bulkTargetsText() string {
+	targets := selectedBulkTargets()
+	if len(targets) == 1 {
+		return fmt.Sprintf("%s '%s'", selectedResource().Name, targets[0])
+	}
+	return fmt.Sprintf("%d %s", len(targets), selectedResource().Name)
+}
+
+// confirmBulk previews the mutation of the targets with a dry run and runs it after confirmation, the result of each item is shown afterwards
+func confirmBulk(action string, targets []bulkTargetType, mutation func(t bulkTargetType) mutationType) {
+	if len(targets) == 0 {
+		return
+	}
+	res := selectedResource()
+	loadingWidget.setContent([]interface{}{fmt.Sprintf("Preview %s of %d %s...", strings.ToLower(action), len(targets), res.Name)}, tpl("loading", loadingTemplate))
+	setState(loadingState)
+	// the dry runs and the lists of the dependents don't block the ui, the preview is shown when they are done
+	be, resources := backend, cfg.resources
+	go func() {
+		preview := be.bulkPreview(resources, targets, mutation)
+		g.Update(func(gui *gocui.Gui) error {
+			if be != backend || currentState.name != loadingState.name {
+				return nil
+			}
+			showPreview(bulkConfirmMessage(action, res.Name, targets), preview, commandType{Name: action, f: func(g *gocui.Gui, v *gocui.View) error {
+				loadingWidget.setContent([]interface{}{fmt.Sprintf("%s %d %s...", action, len(targets), res.Name)}, tpl("loading", loadingTemplate))
+				setState(loadingState)
+				g.Update(func(gui *gocui.Gui) error {
+					results := runBulk(targets, func(t bulkTargetType) error {
+						_, err := backend.mutateObject(mutation(t), false)
+						return err
+					})
+					clearMarks()
+					showBulkResults(bulkSummary(action, res.Name, results))
+					return nil
+				})
+				return nil
+			}})
+			return nil
+		})
+	}()
+}
+
+// showPreview shows the confirm message with the preview, the command runs when it is confirmed
+func showPreview(mess, preview string, command commandType) {
+	if backend.context.policy == confirmTwicePolicy {
+		confirmed := command
+		command = commandType{Name: confirmed.Name, f: func(g *gocui.Gui, v *gocui.View) error {
+			showConfirm(fmt.Sprintf("Context '%s' requires a second confirmation:\n%s", backend.context.Name, mess), confirmed)
+			return nil
+		}}
+	}
+	confirmCommand = command
+	text := fmt.Sprintf("%s\n\n%s\n\n%s", colorizeText(mess, 0, len(mess), yellowEmpInlineColor), preview, colorizeText("[Y]es or [N]o", 0, 13, yellowEmpInlineColor))
+	previewWidget.setContent([]interface{}{text}, tpl("preview", "{{ ind . 0 }}"))
+	previewWidget.yOffset = 0
+	setState(previewState)
+}
+
+func showBulkResults(summary string) {
+	bulkResultWidget.h = min(strings.Count(summary, "\n")+3, maxY-10)
+	bulkResultWidget.setContent([]interface{}{summary}, tpl("bulkResult", "{{ ind . 0 }}"))
+	setState(bulkResultState)
+}
+
+// createFromManifest creates the objects of the manifest file after confirmation, objects without namespace in the selected one
+func createFromManifest(file string) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		showError(fmt.Sprintf("Can't read manifest '%s'", file), err)
+		return
+	}
+	objs, err := parseManifest(data)
 	if err != nil {
-		showError(fmt.Sprintf("Can't delete %s on namespace %s with name '%s' ", res.Name, ns, rname), err)
+		showError(fmt.Sprintf("Invalid manifest '%s'", file), err)
+		return
+	}
+	ns := selectedNamespace()
+	if ns == resItemName(namespaceALL) {
+		ns = backend.context.defaultNamespace()
+	}
+	if ns == "" {
+		ns = "default"
+	}
+	manifestObjs := cfg.resolveManifest(objs, ns)
+	name := filepath.Base(file)
+	loadingWidget.setContent([]interface{}{fmt.Sprintf("Preview %d objects of '%s'...", len(manifestObjs), name)}, tpl("loading", loadingTemplate))
+	setState(loadingState)
+	g.Update(func(gui *gocui.Gui) error {
+		preview := []string{}
+		for _, o := range manifestObjs {
+			preview = append(preview, previewObject(backend, o)...)
+		}
+		showPreview(manifestConfirmMessage(name, manifestObjs), strings.Join(preview, "\n"), commandType{Name: "Create", f: func(g *gocui.Gui, v *gocui.View) error {
+			loadingWidget.setContent([]interface{}{fmt.Sprintf("Create %d objects of '%s'...", len(manifestObjs), name)}, tpl("loading", loadingTemplate))
+			setState(loadingState)
+			g.Update(func(gui *gocui.Gui) error {
+				results := make([]manifestResultType, len(manifestObjs))
+				for i, o := range manifestObjs {
+					results[i], _ = createObject(backend, o, false)
+				}
+				showBulkResults(manifestSummary(name, results))
+				return nil
+			})
+			return nil
+		}})
+		return nil
+	})
+}
+
+// finishEdit previews the edit after the editor is closed, a rejected edit is kept to be edited again
+func finishEdit() {
+	e := pendingEdit
+	if e.err != nil && !e.discarded {
+		showEditError(e)
+		return
 	}
+	if e.discarded || len(e.diff) == 0 {
+		discardEdit()
+		return
+	}
+	g.Update(func(gui *gocui.Gui) error {
+		mess := fmt.Sprintf("Apply edit of %s '%s' ?", e.res.Name, bulkTargetType{namespace: e.namespace, name: e.name})
+		showPreview(mess, strings.Join(previewDiff(e.preview), "\n"), commandType{Name: "Apply edit", f: func(g *gocui.Gui, v *gocui.View) error {
+			e.put(backend)
+			if e.err != nil {
+				showEditError(e)
+				return nil
+			}
+			showBulkResults(e.summary())
+			discardEdit()
+			return nil
+		}})
+		return nil
+	})
 }
 
-func scaleResource(replicas int) {
+func showEditError(e *editType) {
+	showError(fmt.Sprintf("Edit of %s '%s' rejected, press 'e' to edit it again or RETURN to discard it", e.res.Name, e.name), e.err)
+}
+
+func discardEdit() {
+	if pendingEdit != nil {
+		pendingEdit.remove()
+		pendingEdit = nil
+	}
+}
+
+func deleteResources(noGracePeriod bool) {
 	res := selectedResource()
-	rname := selectedResourceItemName()
-	ns := selectedResourceItemNamespace()
-	resDetails := resourceItemsList.widget.items[resourceItemsList.widget.selectedItem]
-	_, err := backend.scale(ns, res, rname, resDetails, replicas)
+	action := "Delete"
+	if noGracePeriod {
+		action = "Delete immediately"
+	}
+	confirmBulk(action, selectedBulkTargets(), func(t bulkTargetType) mutationType {
+		return deleteMutation(t.namespace, res, t.name, noGracePeriod)
+	})
+}
+
+func isRestartable(res resourceType) bool {
+	return res.Name == "deployments" || res.Name == "daemonsets" || res.Name == "statefulsets"
+}
+
+func restartResources() {
+	res := selectedResource()
+	if !isRestartable(res) {
+		return
+	}
+	confirmBulk("Restart", selectedBulkTargets(), func(t bulkTargetType) mutationType {
+		return restartMutation(t.namespace, res, t.name)
+	})
+}
+
+// setLabels sets and removes the labels of the marked items
+func setLabels(text string) {
+	changes, err := parseLabelChanges(text)
 	if err != nil {
-		showError(fmt.Sprintf("Can't scale %s on namespace %s with name '%s' ", res.Name, ns, rname), err)
+		showError(fmt.Sprintf("Invalid labels '%s'", strings.TrimSpace(text)), err)
+		return
 	}
+	res := selectedResource()
+	setState(browseState)
+	confirmBulk(fmt.Sprintf("Label (%s)", strings.TrimSpace(text)), selectedBulkTargets(), func(t bulkTargetType) mutationType {
+		return labelMutation(t.namespace, res, t.name, changes)
+	})
+}
+
+func scaleResource(replicas int) {
+	res := selectedResource()
+	confirmBulk(fmt.Sprintf("Scale by %+d", replicas), selectedBulkTargets(), func(t bulkTargetType) mutationType {
+		return scaleMutation(t.namespace, res, t.name, t.item, replicas)
+	})
 }
 
 func newResource() {
 	selRes := selectedResource()
 	selNs := selectedNamespace()
 
-	resItems := backend.resourceItems(selNs, selRes)
-	updateResourceItemsListTitle(selRes.Name)
-	resourceItemsList.widget.items = resItems
+	resetMarks(backend.context.Name, selNs, selRes.key())
+	updateResourceItemsListTitle(selRes)
+	resourceItemsList.widget.items = listResourceItems(selNs, selRes)
 	resourceItemsList.widget.template = resourceListTpl(selRes)
 	resourceItemsList.widget.selectedPage = 0
 	resourceItemsList.widget.selectedItem = 0
 	updateResourceItemsListFooter()
 }
 
-func updateResourceItemsListTitle(resourceItemName string) {
+func updateResourceItemsListTitle(res resourceType) {
 	var titleTmp string
-	if backend.watches[resourceItemName].online {
-		titleTmp = fmt.Sprintf("  %-30.30s ", resourceItemName)
+	if state := backend.watchState(res.key()); state == watchOnline {
+		titleTmp = fmt.Sprintf("  %-30.30s ", res.Name)
 		resourceItemsList.widget.tableFgColor = gocui.ColorDefault
 	} else {
-		titleTmp = fmt.Sprintf("  %-30.30s  **OFFLINE**", resourceItemName)
+		titleTmp = fmt.Sprintf("  %-30.30s  **%s**", res.Name, state)
 		resourceItemsList.widget.tableFgColor = gocui.ColorRed
 	}
+	if sel := backend.selector(res.key()); !sel.isEmpty() {
+		titleTmp = fmt.Sprintf("%s [%s]", titleTmp, sel)
+	}
+	if quickFilter != "" {
+		titleTmp = fmt.Sprintf("%s /%s", titleTmp, quickFilter)
+	}
+	if len(markedItems) > 0 {
+		titleTmp = fmt.Sprintf("%s (%d marked)", titleTmp, len(markedItems))
+	}
+	switch backend.context.policy {
+	case readOnlyPolicy:
+		titleTmp = fmt.Sprintf("%s  **READ-ONLY**", titleTmp)
+	case confirmTwicePolicy:
+		titleTmp = fmt.Sprintf("%s  **CONFIRM TWICE**", titleTmp)
+	}
 	resourceItemsList.widget.title = titleTmp
+	order := backend.sortOrder(res.key())
+	resourceItemsList.widget.headerItem = map[string]interface{}{"header": "true", "sortColumn": order.sortColumn(), "sortDescending": order.descending}
 }
 
 func leaveResourceItemDetailsPart() {
@@ -987,11 +1484,14 @@ func findInResourceItemDetails(text string) bool {
 func createPortforwardProxy(ns, podName string, pm portMapping) error {
 	p := newPortforwardProxy(ns, podName, pm)
 	k := p.namespace + "/" + p.pod
+	if err := p.execute(); err != nil {
+		return err
+	}
 	if portforwardProxies[k] == nil {
 		portforwardProxies[k] = make([]*portforwardProxy, 0)
 	}
 	portforwardProxies[k] = append(portforwardProxies[k], p)
-	return p.execute()
+	return nil
 }
 
 func removeAllPortforwardProxies() error {
@@ -1040,10 +1540,11 @@ func bindKeys() {
 	bindKey(g, false, keyEventType{Viewname: helpWidget.name, Key: gocui.KeyArrowDown, mod: gocui.ModNone}, scrollDownHelpCommand)
 	bindKey(g, false, keyEventType{Viewname: helpWidget.name, Key: gocui.KeyArrowUp, mod: gocui.ModNone}, scrollUpHelpCommand)
 
-	bindKey(g, false, keyEventType{Viewname: errorWidget.name, Key: gocui.KeyEnter, mod: gocui.ModNone}, quitWidgetCommand)
+	bindKey(g, false, keyEventType{Viewname: errorWidget.name, Key: gocui.KeyEnter, mod: gocui.ModNone}, discardEditCommand)
+	bindKey(g, false, keyEventType{Viewname: errorWidget.name, Key: 'e', mod: gocui.ModNone}, editAgainCommand)
 
-	bindKey(g, false, keyEventType{Viewname: confirmWidget.name, Key: gocui.KeyEnter, mod: gocui.ModNone}, quitWidgetCommand)
-	bindKey(g, false, keyEventType{Viewname: confirmWidget.name, Key: 'n', mod: gocui.ModNone}, quitWidgetCommand)
+	bindKey(g, false, keyEventType{Viewname: confirmWidget.name, Key: gocui.KeyEnter, mod: gocui.ModNone}, discardEditCommand)
+	bindKey(g, false, keyEventType{Viewname: confirmWidget.name, Key: 'n', mod: gocui.ModNone}, discardEditCommand)
 	bindKey(g, false, keyEventType{Viewname: confirmWidget.name, Key: 'y', mod: gocui.ModNone}, executeConfirmCommand)
 
 	bindKey(g, false, keyEventType{Viewname: resourceItemsList.widget.name, Key: gocui.KeyCtrlC, mod: gocui.ModNone}, quitCommand)
@@ -1053,7 +1554,18 @@ func bindKeys() {
 	bindKey(g, false, keyEventType{Viewname: resourceItemsList.widget.name, Key: gocui.KeyArrowUp, mod: gocui.ModNone}, previousLineCommand)
 
 	bindKey(g, false, keyEventType{Viewname: resourceItemsList.widget.name, Key: 'c', mod: gocui.ModNone}, gotoSelectContextStateCommand)
-	bindKey(g, false, keyEventType{Viewname: resourceItemsList.widget.name, Key: gocui.KeySpace, mod: gocui.ModNone}, loadContextCommand)
+	bindKey(g, false, keyEventType{Viewname: resourceItemsList.widget.name, Key: gocui.KeyCtrlR, mod: gocui.ModNone}, loadContextCommand)
+	bindKey(g, false, keyEventType{Viewname: labelWidget.name, Key: gocui.KeyEnter, mod: gocui.ModNone}, setLabelsCommand)
+	bindKey(g, false, keyEventType{Viewname: labelWidget.name, Key: gocui.KeyEsc, mod: gocui.ModNone}, quitWidgetCommand)
+	bindKey(g, false, keyEventType{Viewname: bulkResultWidget.name, Key: gocui.KeyEnter, mod: gocui.ModNone}, quitWidgetCommand)
+	bindKey(g, false, keyEventType{Viewname: bulkResultWidget.name, Key: gocui.KeyEsc, mod: gocui.ModNone}, quitWidgetCommand)
+	bindKey(g, false, keyEventType{Viewname: previewWidget.name, Key: 'y', mod: gocui.ModNone}, executeConfirmCommand)
+	bindKey(g, false, keyEventType{Viewname: previewWidget.name, Key: 'n', mod: gocui.ModNone}, discardEditCommand)
+	bindKey(g, false, keyEventType{Viewname: previewWidget.name, Key: gocui.KeyEsc, mod: gocui.ModNone}, discardEditCommand)
+	bindKey(g, false, keyEventType{Viewname: previewWidget.name, Key: gocui.KeyArrowDown, mod: gocui.ModNone}, scrollDownPreviewCommand)
+	bindKey(g, false, keyEventType{Viewname: previewWidget.name, Key: gocui.KeyArrowUp, mod: gocui.ModNone}, scrollUpPreviewCommand)
+	bindKey(g, false, keyEventType{Viewname: previewWidget.name, Key: gocui.KeyPgdn, mod: gocui.ModNone}, pageDownPreviewCommand)
+	bindKey(g, false, keyEventType{Viewname: previewWidget.name, Key: gocui.KeyPgup, mod: gocui.ModNone}, pageUpPreviewCommand)
 	// bindKey(g,false, keyEventType{Viewname: resourceItemsList.widget.name, Key: 'C', mod: gocui.ModNone}, previousContextCommand)
 	// bindKey(g,false, keyEventType{Viewname: resourceItemsList.widget.name, Key: 'f', mod: gocui.ModNone}, nextNamespaceCommand)
 	bindKey(g, false, keyEventType{Viewname: resourceItemsList.widget.name, Key: 'n', mod: gocui.ModNone}, gotoSelectNamespaceStateCommand)
@@ -1063,22 +1575,19 @@ func bindKeys() {
 	bindKey(g, false, keyEventType{Viewname: resourceItemsList.widget.name, Key: gocui.KeyPgdn, mod: gocui.ModNone}, nextResourceItemListPageCommand)
 	bindKey(g, false, keyEventType{Viewname: resourceItemsList.widget.name, Key: gocui.KeyPgup, mod: gocui.ModNone}, previousResourceItemListPageCommand)
 
-	bindKey(g, false, keyEventType{Viewname: resourceItemsList.widget.name, Key: gocui.KeyDelete, mod: gocui.ModNone}, deleteConfirmCommand)
-	bindKey(g, false, keyEventType{Viewname: resourceItemsList.widget.name, Key: gocui.KeyDelete, mod: gocui.ModAlt}, deleteNoGracePeriodConfirmCommand)
-	bindKey(g, false, keyEventType{Viewname: resourceItemsList.widget.name, Key: '+', mod: gocui.ModNone}, scaleUpCommand)
-	bindKey(g, false, keyEventType{Viewname: resourceItemsList.widget.name, Key: '-', mod: gocui.ModNone}, scaleDownCommand)
 	bindKey(g, false, keyEventType{Viewname: resourceItemsList.widget.name, Key: 'm', mod: gocui.ModNone}, nameSortCommand)
 	bindKey(g, false, keyEventType{Viewname: resourceItemsList.widget.name, Key: 'a', mod: gocui.ModNone}, ageSortCommand)
-	bindKey(g, false, keyEventType{Viewname: resourceItemsList.widget.name, Key: 'x', mod: gocui.ModNone}, execShellCommand0)
-	bindKey(g, false, keyEventType{Viewname: resourceItemsList.widget.name, Key: '1', mod: gocui.ModNone}, execShellCommand0)
-	bindKey(g, false, keyEventType{Viewname: resourceItemsList.widget.name, Key: '2', mod: gocui.ModNone}, execShellCommand1)
-	bindKey(g, false, keyEventType{Viewname: resourceItemsList.widget.name, Key: '3', mod: gocui.ModNone}, execShellCommand2)
-	bindKey(g, false, keyEventType{Viewname: resourceItemsList.widget.name, Key: 'X', mod: gocui.ModNone}, execBashCommand0)
-	bindKey(g, false, keyEventType{Viewname: resourceItemsList.widget.name, Key: '4', mod: gocui.ModNone}, execBashCommand0)
-	bindKey(g, false, keyEventType{Viewname: resourceItemsList.widget.name, Key: '5', mod: gocui.ModNone}, execBashCommand1)
-	bindKey(g, false, keyEventType{Viewname: resourceItemsList.widget.name, Key: '6', mod: gocui.ModNone}, execBashCommand2)
-	bindKey(g, false, keyEventType{Viewname: resourceItemsList.widget.name, Key: 'p', mod: gocui.ModNone}, portForwardSamePortCommand)
-	bindKey(g, false, keyEventType{Viewname: resourceItemsList.widget.name, Key: 'P', mod: gocui.ModNone}, portForwardCommand)
+	bindKey(g, false, keyEventType{Viewname: resourceItemsList.widget.name, Key: 's', mod: gocui.ModNone}, columnSortCommand)
+	bindKey(g, false, keyEventType{Viewname: resourceItemsList.widget.name, Key: 'S', mod: gocui.ModNone}, reverseSortCommand)
+	bindKey(g, false, keyEventType{Viewname: resourceItemsList.widget.name, Key: 'l', mod: gocui.ModNone}, gotoSelectorStateCommand)
+	bindKey(g, false, keyEventType{Viewname: selectorWidget.name, Key: gocui.KeyEnter, mod: gocui.ModNone}, setSelectorCommand)
+	bindKey(g, false, keyEventType{Viewname: selectorWidget.name, Key: gocui.KeyEsc, mod: gocui.ModNone}, quitWidgetCommand)
+	bindKey(g, false, keyEventType{Viewname: resourceItemsList.widget.name, Key: '/', mod: gocui.ModNone}, gotoQuickFilterStateCommand)
+	bindKey(g, false, keyEventType{Viewname: resourceItemsList.widget.name, Key: gocui.KeyEsc, mod: gocui.ModNone}, clearQuickFilterCommand)
+	bindKey(g, false, keyEventType{Viewname: quickFilterWidget.name, Key: gocui.KeyEnter, mod: gocui.ModNone}, quitWidgetCommand)
+	bindKey(g, false, keyEventType{Viewname: quickFilterWidget.name, Key: gocui.KeyEsc, mod: gocui.ModNone}, clearQuickFilterCommand)
+	bindKey(g, false, keyEventType{Viewname: quickFilterWidget.name, Key: gocui.KeyArrowDown, mod: gocui.ModNone}, nextLineCommand)
+	bindKey(g, false, keyEventType{Viewname: quickFilterWidget.name, Key: gocui.KeyArrowUp, mod: gocui.ModNone}, previousLineCommand)
 
 	bindKey(g, false, keyEventType{Viewname: searchmodeWidget.name, Key: gocui.KeyArrowRight, mod: gocui.ModNone}, nextResourceItemDetailPartCommand)
 	bindKey(g, false, keyEventType{Viewname: searchmodeWidget.name, Key: gocui.KeyArrowLeft, mod: gocui.ModNone}, previousResourceItemDetailPartCommand)
@@ -1107,14 +1616,13 @@ func bindKeys() {
 	bindKey(g, false, keyEventType{Viewname: namespaceList.widget.name, Key: gocui.KeyPgup, mod: gocui.ModNone}, previousNamespacePageCommand)
 
 	bindKey(g, false, keyEventType{Viewname: clusterList.widget.name, Key: gocui.KeyEnter, mod: gocui.ModNone}, loadContextCommand)
+	bindKey(g, false, keyEventType{Viewname: clusterList.widget.name, Key: 'r', mod: gocui.ModNone}, checkContextCommand)
 	bindKey(g, false, keyEventType{Viewname: clusterList.widget.name, Key: gocui.KeyArrowUp, mod: gocui.ModNone}, previousContextCommand)
 	bindKey(g, false, keyEventType{Viewname: clusterList.widget.name, Key: gocui.KeyArrowDown, mod: gocui.ModNone}, nextContextCommand)
 	bindKey(g, false, keyEventType{Viewname: clusterList.widget.name, Key: gocui.KeyPgdn, mod: gocui.ModNone}, nextContextPageCommand)
 	bindKey(g, false, keyEventType{Viewname: clusterList.widget.name, Key: gocui.KeyPgup, mod: gocui.ModNone}, previousContextPageCommand)
 	bindKey(g, false, keyEventType{Viewname: clusterList.widget.name, Key: gocui.KeyCtrlC, mod: gocui.ModNone}, quitWidgetCommand)
 
-	bindKey(g, false, keyEventType{Viewname: resourceItemsList.widget.name, Key: 'u', mod: gocui.ModNone}, uploadFileCommand)
-	bindKey(g, false, keyEventType{Viewname: resourceItemsList.widget.name, Key: 'd', mod: gocui.ModNone}, downloadFileCommand)
 	bindKey(g, false, keyEventType{Viewname: fileList.widget.name, Key: gocui.KeyEnter, mod: gocui.ModNone}, gotoFileCommand)
 	bindKey(g, false, keyEventType{Viewname: fileList.widget.name, Key: gocui.KeyArrowUp, mod: gocui.ModNone}, previousFileCommand)
 	bindKey(g, false, keyEventType{Viewname: fileList.widget.name, Key: gocui.KeyArrowDown, mod: gocui.ModNone}, nextFileCommand)
@@ -1122,7 +1630,57 @@ func bindKeys() {
 	bindKey(g, false, keyEventType{Viewname: fileList.widget.name, Key: gocui.KeyPgup, mod: gocui.ModNone}, previousFilePageCommand)
 	bindKey(g, false, keyEventType{Viewname: fileList.widget.name, Key: gocui.KeyCtrlO, mod: gocui.ModNone}, nextContainerFiletransferCommand)
 	bindKey(g, false, keyEventType{Viewname: fileList.widget.name, Key: gocui.KeyCtrlC, mod: gocui.ModNone}, quitWidgetCommand)
-
+	updateMutationKeys()
+}
+
+// bindMutationKeys binds the commands, which change the cluster
+func bindMutationKeys() {
+	bindMutationKey(keyEventType{Viewname: resourceItemsList.widget.name, Key: gocui.KeySpace, mod: gocui.ModNone}, toggleMarkCommand)
+	bindMutationKey(keyEventType{Viewname: resourceItemsList.widget.name, Key: gocui.KeyInsert, mod: gocui.ModNone}, toggleMarkCommand)
+	bindMutationKey(keyEventType{Viewname: resourceItemsList.widget.name, Key: '*', mod: gocui.ModNone}, toggleMarksCommand)
+	bindMutationKey(keyEventType{Viewname: resourceItemsList.widget.name, Key: 'R', mod: gocui.ModNone}, restartConfirmCommand)
+	bindMutationKey(keyEventType{Viewname: resourceItemsList.widget.name, Key: 'L', mod: gocui.ModNone}, gotoLabelStateCommand)
+	bindMutationKey(keyEventType{Viewname: resourceItemsList.widget.name, Key: 'e', mod: gocui.ModNone}, editCommand)
+	bindMutationKey(keyEventType{Viewname: resourceItemsList.widget.name, Key: 'A', mod: gocui.ModNone}, createFromManifestCommand)
+	bindMutationKey(keyEventType{Viewname: resourceItemsList.widget.name, Key: gocui.KeyDelete, mod: gocui.ModNone}, deleteConfirmCommand)
+	bindMutationKey(keyEventType{Viewname: resourceItemsList.widget.name, Key: gocui.KeyDelete, mod: gocui.ModAlt}, deleteNoGracePeriodConfirmCommand)
+	bindMutationKey(keyEventType{Viewname: resourceItemsList.widget.name, Key: '+', mod: gocui.ModNone}, scaleUpCommand)
+	bindMutationKey(keyEventType{Viewname: resourceItemsList.widget.name, Key: '-', mod: gocui.ModNone}, scaleDownCommand)
+	bindMutationKey(keyEventType{Viewname: resourceItemsList.widget.name, Key: 'x', mod: gocui.ModNone}, execShellCommand0)
+	bindMutationKey(keyEventType{Viewname: resourceItemsList.widget.name, Key: '1', mod: gocui.ModNone}, execShellCommand0)
+	bindMutationKey(keyEventType{Viewname: resourceItemsList.widget.name, Key: '2', mod: gocui.ModNone}, execShellCommand1)
+	bindMutationKey(keyEventType{Viewname: resourceItemsList.widget.name, Key: '3', mod: gocui.ModNone}, execShellCommand2)
+	bindMutationKey(keyEventType{Viewname: resourceItemsList.widget.name, Key: 'X', mod: gocui.ModNone}, execBashCommand0)
+	bindMutationKey(keyEventType{Viewname: resourceItemsList.widget.name, Key: '4', mod: gocui.ModNone}, execBashCommand0)
+	bindMutationKey(keyEventType{Viewname: resourceItemsList.widget.name, Key: '5', mod: gocui.ModNone}, execBashCommand1)
+	bindMutationKey(keyEventType{Viewname: resourceItemsList.widget.name, Key: '6', mod: gocui.ModNone}, execBashCommand2)
+	bindMutationKey(keyEventType{Viewname: resourceItemsList.widget.name, Key: 'p', mod: gocui.ModNone}, portForwardSamePortCommand)
+	bindMutationKey(keyEventType{Viewname: resourceItemsList.widget.name, Key: 'P', mod: gocui.ModNone}, portForwardCommand)
+	bindMutationKey(keyEventType{Viewname: resourceItemsList.widget.name, Key: 'u', mod: gocui.ModNone}, uploadFileCommand)
+	bindMutationKey(keyEventType{Viewname: resourceItemsList.widget.name, Key: 'd', mod: gocui.ModNone}, downloadFileCommand)
+}
+
+func bindMutationKey(keyBind keyEventType, command commandType) {
+	bindKey(g, false, keyBind, command)
+	mutationKeyEvents[keyBind] = true
+}
+
+// updateMutationKeys binds the commands, which change the cluster, if the policy of the context allows them
+func updateMutationKeys() {
+	kept := []keyBindingType{}
+	for _, kb := range keyBindings {
+		if !mutationKeyEvents[kb.KeyEvent] {
+			kept = append(kept, kb)
+			continue
+		}
+		if err := g.DeleteKeybinding(kb.KeyEvent.Viewname, kb.KeyEvent.Key, kb.KeyEvent.mod); err != nil {
+			errorlog.Panicln(err)
+		}
+	}
+	keyBindings = kept
+	if !backend.context.readOnly() {
+		bindMutationKeys()
+	}
 }
 
 func toIfc(cis []contextType) []interface{} {
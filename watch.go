@@ -0,0 +1,573 @@
+package kubexp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	neturl "net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// delays between reconnects of a failed watch, they double with every failure up to the maximum
+var watchBackoffInitial = 500 * time.Millisecond
+var watchBackoffMax = 30 * time.Second
+
+// watches request a random timeoutSeconds between watchTimeoutSeconds and twice as much, so the watches of all resources don't roll over at once
+var watchTimeoutSeconds = 300
+
+// a watch stream which ends earlier than this counts as failure and is reconnected with backoff
+var watchMinDuration = time.Second
+
+// errForbidden the user may not list or watch the resource
+var errForbidden = errors.New("forbidden")
+
+// states of the watches of a resource
+const (
+	watchLoading   = "LOADING"
+	watchOnline    = "online"
+	watchOffline   = "OFFLINE"
+	watchForbidden = "FORBIDDEN"
+)
+
+// watches of resources which were not used for watchTTL seconds are stopped
+var watchTTL = 300
+
+// lists are fetched in chunks of this many items
+var listChunkSize = 500
+
+// resources which can't be watched are listed again after watchPollInterval
+var watchPollInterval = 30 * time.Second
+
+// errWatchExpired the resourceVersion of the watch is too old (http status 410 Gone), the resource must be listed again
+var errWatchExpired = errors.New("resourceVersion expired")
+
+// namespacesKey, crdKey keys of the resources which are always watched
+const (
+	namespacesKey = "namespaces"
+	crdKey        = "apiextensions.k8s.io/customresourcedefinitions"
+)
+
+type watchType struct {
+	apiPrefix, resName, namespace string
+	mutex                         sync.Mutex
+	reader                        io.ReadCloser
+	state                         string
+	resourceVersion               string
+	done                          chan struct{}
+	closed                        bool
+	// poll the resource has no watch verb, it is listed periodically
+	poll bool
+	// resKey key of the resource in the store, see resourceType.key
+	resKey string
+	// selector query parameters of the selector of the resource, when selectors are sent to the api server
+	selector string
+	// fetched, total items fetched so far while listing the resource, total is 0 if the api server doesn't estimate it
+	fetched, total int
+}
+
+func newWatch() *watchType {
+	return &watchType{done: make(chan struct{}), state: watchLoading}
+}
+
+// newResourceWatch watches the resource in namespace ns, or cluster wide if ns is empty
+func newResourceWatch(res resourceType, ns string) *watchType {
+	w := newWatch()
+	w.apiPrefix, w.resName, w.namespace, w.resKey = res.APIPrefix, res.Name, ns, res.key()
+	w.poll = !res.Watch
+	return w
+}
+
+func (w *watchType) key() string {
+	if w.namespace == "" {
+		return w.resKey
+	}
+	return storeKey(w.namespace, w.resKey)
+}
+
+func (w *watchType) String() string {
+	if w.namespace == "" {
+		return w.resName
+	}
+	return fmt.Sprintf("%s in namespace %s", w.resName, w.namespace)
+}
+
+// path of the resource, e.g. 'api/v1/namespaces/default/pods' or 'api/v1/watch/pods'
+func (w *watchType) path(watch bool) string {
+	p := w.apiPrefix
+	if watch {
+		p = p + "/watch"
+	}
+	if w.namespace != "" {
+		p = fmt.Sprintf("%s/namespaces/%s", p, w.namespace)
+	}
+	return p + "/" + w.resName
+}
+
+func (w *watchType) isForbidden() bool {
+	return w.getState() == watchForbidden
+}
+
+func (w *watchType) setForbidden() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.state = watchForbidden
+}
+
+func (w *watchType) getState() string {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.state
+}
+
+func (w *watchType) isOnline() bool {
+	return w.getState() == watchOnline
+}
+
+// setOnline a forbidden watch stays forbidden when it goes offline
+func (w *watchType) setOnline(online bool) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if online {
+		w.state = watchOnline
+	} else if w.state != watchForbidden {
+		w.state = watchOffline
+	}
+}
+
+func (w *watchType) getResourceVersion() string {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.resourceVersion
+}
+
+func (w *watchType) setResourceVersion(rv string) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.resourceVersion = rv
+}
+
+// selectorQuery the selector as additional query parameters
+func (w *watchType) selectorQuery() string {
+	if w.selector == "" {
+		return ""
+	}
+	return "&" + w.selector
+}
+
+func (w *watchType) setProgress(fetched, total int) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.fetched, w.total = fetched, total
+}
+
+func (w *watchType) getProgress() (int, int) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.fetched, w.total
+}
+
+// setReader returns false when the watch is already closed, the reader is closed then
+func (w *watchType) setReader(reader io.ReadCloser) bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closed {
+		reader.Close()
+		return false
+	}
+	w.reader = reader
+	return true
+}
+
+func (w *watchType) isClosed() bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.closed
+}
+
+// close stops the watch, a running stream is interrupted
+func (w *watchType) close() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closed {
+		return
+	}
+	w.closed = true
+	w.state = watchOffline
+	close(w.done)
+	if w.reader != nil {
+		w.reader.Close()
+	}
+}
+
+// sleep returns false when the watch was closed while waiting
+func (w *watchType) sleep(d time.Duration) bool {
+	select {
+	case <-time.After(d):
+		return true
+	case <-w.done:
+		return false
+	}
+}
+
+type backoffType struct {
+	initial, max, current time.Duration
+}
+
+// next doubles the delay and returns it with jitter, between the half and the full delay
+func (bo *backoffType) next() time.Duration {
+	if bo.current == 0 {
+		bo.current = bo.initial
+	} else {
+		bo.current *= 2
+	}
+	if bo.current > bo.max {
+		bo.current = bo.max
+	}
+	half := int64(bo.current / 2)
+	return time.Duration(half + rand.Int63n(half+1))
+}
+
+func (bo *backoffType) reset() {
+	bo.current = 0
+}
+
+// watch lists the resource and keeps it up to date with a watch, which is resumed with the last resourceVersion when the connection breaks.
+// When this is forbidden cluster wide, namespaced resources are watched in each of the fallbackNamespaces. Resources without watch verb are listed every watchPollInterval
+// GET /api/v1/pods
+// GET /api/v1/watch/pods?resourceVersion=4711&allowWatchBookmarks=true&timeoutSeconds=300
+func (b *backendType) watch(res resourceType) error {
+	err := b.startWatch(newResourceWatch(res, ""))
+	if err != errForbidden {
+		return err
+	}
+	if !res.Namespace {
+		warninglog.Printf("watching resource %s is forbidden", res.Name)
+		return nil
+	}
+	namespaces := b.fallbackNamespaces()
+	infolog.Printf("watching resource %s cluster wide is forbidden, watching namespaces %v", res.Name, namespaces)
+	b.removeWatch(res.key())
+	for _, ns := range namespaces {
+		err := b.startWatch(newResourceWatch(res, ns))
+		if err != nil && err != errForbidden {
+			return err
+		}
+	}
+	return nil
+}
+
+// startWatch lists the resource and keeps watching it, unless this is forbidden. When listing fails, it is retried
+func (b *backendType) startWatch(w *watchType) error {
+	if serverSelector {
+		w.selector = b.selector(w.resKey).query()
+	}
+	b.setWatch(w.key(), w)
+	err := b.list(w)
+	if err == errForbidden {
+		return err
+	}
+	if err != nil {
+		w.setOnline(false)
+	}
+	go b.keepWatching(w)
+	return err
+}
+
+// useWatch requests the watch of the resource from the update loop when it is used for the first time, and keeps it from being stopped as idle
+func (b *backendType) useWatch(rt resourceType) {
+	b.mutex.Lock()
+	_, watched := b.lastUsed[rt.key()]
+	b.lastUsed[rt.key()] = time.Now()
+	b.mutex.Unlock()
+	if watched {
+		return
+	}
+	select {
+	case b.watchRequests <- rt:
+	default:
+		// update loop is busy or stopped, the watch is requested again when the resource is used next time
+		b.mutex.Lock()
+		delete(b.lastUsed, rt.key())
+		b.mutex.Unlock()
+	}
+}
+
+// stopIdleWatches stops the watches of resources, which were not used for watchTTL seconds, and drops their items. Namespaces and custom resource definitions are always watched
+func (b *backendType) stopIdleWatches() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for resKey, used := range b.lastUsed {
+		if resKey == namespacesKey || resKey == crdKey || time.Since(used) < time.Duration(watchTTL)*time.Second {
+			continue
+		}
+		infolog.Printf("stop watching idle resource %s", resKey)
+		b.stopWatches0(resKey)
+	}
+}
+
+// stopWatches0 stops the watches of the resource and drops its items, b.mutex must be held
+func (b *backendType) stopWatches0(resKey string) {
+	for key, w := range b.watches {
+		if w.resKey == resKey {
+			w.close()
+			delete(b.watches, key)
+		}
+	}
+	delete(b.lastUsed, resKey)
+	b.store.drop(resKey)
+}
+
+// fallbackNamespaces namespaces to watch when a resource may not be watched cluster wide:
+// the -watchNamespaces flag, all namespaces if they can be listed, or the namespace of the context
+func (b *backendType) fallbackNamespaces() []string {
+	if watchNamespaces != nil && *watchNamespaces != "" {
+		namespaces := []string{}
+		for _, ns := range strings.Split(*watchNamespaces, ",") {
+			if ns = strings.TrimSpace(ns); ns != "" {
+				namespaces = append(namespaces, ns)
+			}
+		}
+		return namespaces
+	}
+	if nss := b.store.list(namespacesKey); len(nss) > 0 {
+		namespaces := make([]string, len(nss))
+		for i, ns := range nss {
+			namespaces[i] = resItemName(ns)
+		}
+		sort.Strings(namespaces)
+		return namespaces
+	}
+	if ns := b.context.defaultNamespace(); ns != "" {
+		return []string{ns}
+	}
+	return []string{"default"}
+}
+
+func (b *backendType) keepWatching(w *watchType) {
+	backoff := backoffType{initial: watchBackoffInitial, max: watchBackoffMax}
+	for !w.isClosed() {
+		if w.getResourceVersion() == "" {
+			if err := b.list(w); err != nil {
+				w.setOnline(false)
+				delay := backoff.next()
+				warninglog.Printf("listing resource %s failed, retry in %v: %v", w, delay, err)
+				w.sleep(delay)
+				continue
+			}
+		}
+		if w.poll {
+			backoff.reset()
+			w.sleep(watchPollInterval)
+			w.setResourceVersion("")
+			continue
+		}
+		start := time.Now()
+		err := b.watchStream(w)
+		switch {
+		case w.isClosed():
+			tracelog.Printf("watch of resource %s closed", w)
+		case err == errWatchExpired:
+			infolog.Printf("watch of resource %s expired, listing again", w)
+			w.setResourceVersion("")
+		case err != nil || time.Since(start) < watchMinDuration:
+			w.setOnline(false)
+			delay := backoff.next()
+			warninglog.Printf("watch of resource %s ended, reconnect in %v: %v", w, delay, err)
+			w.sleep(delay)
+		default:
+			tracelog.Printf("watch of resource %s ended, reconnecting with resourceVersion %s", w, w.getResourceVersion())
+			backoff.reset()
+		}
+	}
+}
+
+// listPageType page of a list, or of a table when the api server renders tables
+type listPageType struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Metadata   struct {
+		ResourceVersion    string `json:"resourceVersion"`
+		Continue           string `json:"continue"`
+		RemainingItemCount *int   `json:"remainingItemCount"`
+	} `json:"metadata"`
+	Items []map[string]interface{} `json:"items"`
+	tableType
+}
+
+// objects the items of the page, or the objects of the rows of a table
+func (page *listPageType) objects() []interface{} {
+	items := page.Items
+	for _, row := range page.Rows {
+		items = append(items, row.Object)
+	}
+	objects := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		if item == nil {
+			continue
+		}
+		// items of a list have no type information, unlike the objects of watch events
+		if item["kind"] == nil && page.Kind != "Table" {
+			item["kind"] = strings.TrimSuffix(page.Kind, "List")
+		}
+		if item["apiVersion"] == nil {
+			item["apiVersion"] = page.APIVersion
+		}
+		objects = append(objects, item)
+	}
+	return objects
+}
+
+// list replaces the items of the resource and remembers the resourceVersion of the list to watch from.
+// Large lists are fetched in chunks of listChunkSize items, when the continue token expires meanwhile the list is started again
+// GET /api/v1/pods?limit=500
+// GET /api/v1/pods?limit=500&continue=ENCODED_CONTINUE_TOKEN
+func (b *backendType) list(w *watchType) error {
+	defer w.setProgress(0, 0)
+	items := []interface{}{}
+	table := tableType{}
+	rv, token, restarted := "", "", false
+	for {
+		page, err := b.listPage(w, token)
+		if err == errWatchExpired && token != "" && !restarted {
+			infolog.Printf("continue token of resource %s expired, listing again", w)
+			items, table, token, restarted = []interface{}{}, tableType{}, "", true
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if token == "" {
+			// all chunks are from the snapshot of the first one
+			rv = page.Metadata.ResourceVersion
+			table.ColumnDefinitions = page.ColumnDefinitions
+		}
+		if page.Kind == "Table" {
+			table.Rows = append(table.Rows, page.Rows...)
+		}
+		items = append(items, page.objects()...)
+		total := 0
+		if page.Metadata.RemainingItemCount != nil {
+			total = len(items) + *page.Metadata.RemainingItemCount
+		}
+		w.setProgress(len(items), total)
+		if token = page.Metadata.Continue; token == "" {
+			break
+		}
+	}
+	tracelog.Printf("listed %d items of resource %s, resourceVersion: %s", len(items), w, rv)
+	if w.isClosed() {
+		// stopped while listing, the items of a stopped watch are dropped
+		return nil
+	}
+	if w.namespace == "" {
+		b.store.replace(w.resKey, items)
+	} else {
+		b.store.replaceNamespace(w.resKey, w.namespace, items)
+	}
+	if len(table.ColumnDefinitions) > 0 {
+		b.store.setTable(w.resKey, table)
+	}
+	w.setResourceVersion(rv)
+	w.setOnline(true)
+	if w.resKey == namespacesKey {
+		updateNamespaces()
+	}
+	if w.resKey == crdKey {
+		updateCustomResources()
+	}
+	b.resourceItemsChanged(w.resKey, "*ALL*")
+	return nil
+}
+
+// listPage fetches the chunk of the list which starts at the continue token, or the first one if token is empty
+func (b *backendType) listPage(w *watchType, token string) (*listPageType, error) {
+	query := fmt.Sprintf("?limit=%d", listChunkSize)
+	query = query + w.selectorQuery()
+	if token != "" {
+		query = fmt.Sprintf("%s&continue=%s", query, neturl.QueryEscape(token))
+	}
+	url := fmt.Sprintf("%s/%s%s", b.context.Cluster.URL, w.path(false), withTableQuery(query))
+	resp, err := b.restExecutor(http.MethodGet, url, "", restCallTimeout, tableHeader())
+	if err != nil {
+		errorlog.Printf("error listing resource %s : %v", w, err)
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusForbidden:
+		w.setForbidden()
+		tracelog.Printf("listing resource %s is forbidden", w)
+		return nil, errForbidden
+	case http.StatusGone:
+		return nil, errWatchExpired
+	default:
+		mess := fmt.Sprintf("error listing resource %s: http status %s", w, resp.Status)
+		if resp.StatusCode == http.StatusUnauthorized {
+			mess = mess + "\nPlease check your cluster rbac settings!"
+		}
+		errorlog.Printf(mess)
+		return nil, errors.New(mess)
+	}
+	var page listPageType
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return nil, fmt.Errorf("can't decode list of resource %s: %v", w, err)
+	}
+	return &page, nil
+}
+
+// watchStream processes watch events until the stream ends, an error or the expiration of the resourceVersion
+func (b *backendType) watchStream(w *watchType) error {
+	timeout := watchTimeoutSeconds + rand.Intn(watchTimeoutSeconds+1)
+	url := fmt.Sprintf("%s/%s%s", b.context.Cluster.URL, w.path(true), withTableQuery(fmt.Sprintf("?resourceVersion=%s&allowWatchBookmarks=true&timeoutSeconds=%d%s", w.getResourceVersion(), timeout, w.selectorQuery())))
+	tracelog.Printf("watching resource : %s", url)
+	resp, err := b.restExecutor(http.MethodGet, url, "", -1, tableHeader())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusGone:
+		return errWatchExpired
+	default:
+		return fmt.Errorf("error watching resource %s: http status %s", w, resp.Status)
+	}
+	if !w.setReader(resp.Body) {
+		return nil
+	}
+	w.setOnline(true)
+	reader := bufio.NewReader(resp.Body)
+	for {
+		watchBytes, err := reader.ReadBytes('\n')
+		if err != nil {
+			if err == io.EOF || w.isClosed() {
+				return nil
+			}
+			return err
+		}
+		watch := b.unwrapTableEvent(w.resKey, unmarshallBytes(watchBytes))
+		if watch["type"] == "ERROR" {
+			if val1(watch, "{{.object.code}}") == "410" {
+				return errWatchExpired
+			}
+			return fmt.Errorf("error event watching resource %s: %s", w, val1(watch, "{{.object.message}}"))
+		}
+		// bookmarks carry the latest resourceVersion, even if no item of the resource changed
+		if watchObj, ok := watch["object"].(map[string]interface{}); ok {
+			w.setResourceVersion(resItemResourceVersion(watchObj))
+		}
+		if w.isClosed() {
+			return nil
+		}
+		w.setOnline(true)
+		b.updateResourceItems(w.resKey, watch)
+	}
+}
@@ -0,0 +1,339 @@
+package kubexp
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testWatchServer serves pods, watch events are taken from the events map by resourceVersion, unknown versions block
+type testWatchServer struct {
+	*httptest.Server
+	mutex    sync.Mutex
+	lists    int
+	watchRVs []string
+	events   map[string]string
+}
+
+func newTestWatchServer(events map[string]string) *testWatchServer {
+	s := &testWatchServer{events: events}
+	s.Server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mutex.Lock()
+		switch r.URL.Path {
+		case "/api/v1/pods":
+			s.lists++
+			rv := s.lists * 10
+			s.mutex.Unlock()
+			fmt.Fprintf(w, `{"kind":"PodList","apiVersion":"v1","metadata":{"resourceVersion":"%d"},"items":[{"metadata":{"name":"listed-%d","namespace":"default","resourceVersion":"%d"}}]}`, rv, rv, rv)
+		case "/api/v1/watch/pods":
+			if r.URL.Query().Get("allowWatchBookmarks") != "true" || r.URL.Query().Get("timeoutSeconds") == "" {
+				s.mutex.Unlock()
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			rv := r.URL.Query().Get("resourceVersion")
+			s.watchRVs = append(s.watchRVs, rv)
+			events, found := s.events[rv]
+			s.mutex.Unlock()
+			switch {
+			case events == "410":
+				w.WriteHeader(http.StatusGone)
+			case found:
+				fmt.Fprint(w, events)
+			default:
+				w.WriteHeader(http.StatusOK)
+				w.(http.Flusher).Flush()
+				<-r.Context().Done()
+			}
+		default:
+			s.mutex.Unlock()
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return s
+}
+
+func (s *testWatchServer) watchedWith(rv string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, r := range s.watchRVs {
+		if r == rv {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *testWatchServer) listCount() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.lists
+}
+
+var podResource = resourceType{Name: "pods", APIPrefix: "api/v1", Namespace: true, Watch: true}
+
+func newTestWatchBackend(server *httptest.Server) *backendType {
+	ctx := contextType{Name: "test"}
+	if server != nil {
+		ctx = testContext(server, clusterType{insecureSkipTLSVerify: true})
+	}
+	be := newBackend(ctx)
+	return be
+}
+
+func Test_WatchResumesWithResourceVersion(t *testing.T) {
+	require := require.New(t)
+	defer func(d time.Duration) { watchMinDuration = d }(watchMinDuration)
+	watchMinDuration = 0
+	server := newTestWatchServer(map[string]string{
+		"10": `{"type":"ADDED","object":{"kind":"Pod","metadata":{"name":"added","namespace":"default","resourceVersion":"11"}}}` + "\n",
+	})
+	defer server.Close()
+
+	be := newTestWatchBackend(server.Server)
+	require.Nil(be.watch(podResource))
+	defer be.watchOf("pods").close()
+	require.Eventually(func() bool { return server.watchedWith("11") }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(1, server.listCount(), "reconnect must not list again")
+	require.True(be.watchOf("pods").isOnline())
+}
+
+func Test_WatchListsAgainWhenExpired(t *testing.T) {
+	require := require.New(t)
+	defer func(d time.Duration) { watchMinDuration = d }(watchMinDuration)
+	watchMinDuration = 0
+	server := newTestWatchServer(map[string]string{
+		"10": `{"type":"ERROR","object":{"kind":"Status","code":410,"message":"too old resource version"}}` + "\n",
+		"20": "410",
+	})
+	defer server.Close()
+
+	be := newTestWatchBackend(server.Server)
+	require.Nil(be.watch(podResource))
+	defer be.watchOf("pods").close()
+	require.Eventually(func() bool { return server.watchedWith("30") }, 5*time.Second, 10*time.Millisecond)
+	require.Equal("listed-30", resItemName(be.store.list("pods")[0]))
+	require.Equal("Pod", be.store.list("pods")[0].(map[string]interface{})["kind"])
+}
+
+func Test_WatchRollsOverWithBookmark(t *testing.T) {
+	require := require.New(t)
+	defer func(d time.Duration) { watchMinDuration = d }(watchMinDuration)
+	watchMinDuration = 0
+	server := newTestWatchServer(map[string]string{
+		"10": `{"type":"ADDED","object":{"kind":"Pod","metadata":{"name":"listed-10","namespace":"default","resourceVersion":"10"}}}` + "\n" +
+			`{"type":"BOOKMARK","object":{"kind":"Pod","metadata":{"resourceVersion":"15"}}}` + "\n",
+	})
+	defer server.Close()
+
+	be := newTestWatchBackend(server.Server)
+	require.Nil(be.watch(podResource))
+	defer be.watchOf("pods").close()
+	require.Eventually(func() bool { return server.watchedWith("15") }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(1, server.listCount(), "roll over must not list again")
+	require.Len(be.store.list("pods"), 1, "replayed event must not duplicate the item, bookmark must not add one")
+}
+
+func Test_WatchEventsIdentifyItemsByNamespaceAndName(t *testing.T) {
+	require := require.New(t)
+	be := newTestWatchBackend(nil)
+	event := func(typ, ns, phase string) map[string]interface{} {
+		return unmarshall(fmt.Sprintf(`{"type":"%s","object":{"metadata":{"name":"web","namespace":"%s"},"status":{"phase":"%s"}}}`, typ, ns, phase))
+	}
+	be.updateResourceItems("pods", event("ADDED", "dev", "Pending"))
+	be.updateResourceItems("pods", event("ADDED", "prod", "Pending"))
+	be.updateResourceItems("pods", event("MODIFIED", "prod", "Running"))
+	require.Len(be.store.list("pods"), 2)
+	require.Equal("Pending", val1(be.store.get("pods", "dev", "web"), "{{.status.phase}}"))
+	require.Equal("Running", val1(be.store.get("pods", "prod", "web"), "{{.status.phase}}"))
+
+	be.updateResourceItems("pods", event("DELETED", "dev", "Running"))
+	require.Len(be.store.list("pods"), 1)
+	require.Equal("prod", resItemNamespace(be.store.list("pods")[0]))
+}
+
+func Test_WatchFallsBackToNamespaces(t *testing.T) {
+	require := require.New(t)
+	defer func(d time.Duration) { watchMinDuration = d }(watchMinDuration)
+	watchMinDuration = 0
+	ns := "dev,forbidden"
+	watchNamespaces = &ns
+	defer func() { watchNamespaces = nil }()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/namespaces/dev/pods":
+			fmt.Fprint(w, `{"kind":"PodList","apiVersion":"v1","metadata":{"resourceVersion":"10"},"items":[{"metadata":{"name":"web","namespace":"dev"}}]}`)
+		case "/api/v1/watch/namespaces/dev/pods":
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		default:
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer server.Close()
+
+	be := newTestWatchBackend(server)
+	defer be.closeWatches0(func(string) bool { return true })
+	require.Nil(be.watch(podResource))
+	require.Nil(be.watch(resourceType{Name: "nodes", APIPrefix: "api/v1"}))
+	require.Equal([]string{"dev", "forbidden"}, be.fallbackNamespaces())
+	require.Len(be.store.list("pods"), 1)
+	require.NotNil(be.store.get("pods", "dev", "web"))
+	require.Nil(be.watchOf("pods"), "cluster wide watch must be removed")
+	require.Equal(watchOnline, be.watchState("pods"))
+	require.Equal(watchForbidden, be.watchState("nodes"))
+}
+
+func Test_WatchOnDemandAndStopWhenIdle(t *testing.T) {
+	require := require.New(t)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/namespaces":
+			fmt.Fprint(w, `{"kind":"NamespaceList","apiVersion":"v1","metadata":{"resourceVersion":"10"},"items":[{"metadata":{"name":"dev"}}]}`)
+		case "/api/v1/pods":
+			fmt.Fprint(w, `{"kind":"PodList","apiVersion":"v1","metadata":{"resourceVersion":"10"},"items":[{"metadata":{"name":"web","namespace":"dev"}}]}`)
+		case "/api/v1/watch/namespaces", "/api/v1/watch/pods":
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer server.Close()
+	nsResource := resourceType{Name: "namespaces", APIPrefix: "api/v1", Watch: true}
+	podResource := resourceType{Name: "pods", APIPrefix: "api/v1", Namespace: true, Watch: true}
+
+	be := newTestWatchBackend(server)
+	require.Nil(be.createWatches([]resourceType{nsResource, podResource}))
+	defer be.closeWatches()
+	require.Len(be.store.list("namespaces"), 1)
+	require.Nil(be.watchOf("pods"), "pods must not be watched before they are used")
+	require.False(be.isEmpty("*ALL*", podResource))
+
+	require.Eventually(func() bool { return len(be.resourceItems("*ALL*", podResource)) == 1 }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(watchOnline, be.watchState("pods"))
+
+	defer func(ttl int) { watchTTL = ttl }(watchTTL)
+	watchTTL = 0
+	require.Eventually(func() bool { return be.watchOf("pods") == nil }, 5*time.Second, 10*time.Millisecond)
+	require.Empty(be.store.list("pods"))
+	require.NotNil(be.watchOf("namespaces"), "namespaces must be watched always")
+}
+
+func Test_WatchStoppedWhileListing(t *testing.T) {
+	require := require.New(t)
+	listing, release := make(chan bool, 1), make(chan bool)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/pods" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		listing <- true
+		<-release
+		fmt.Fprint(w, `{"kind":"PodList","apiVersion":"v1","metadata":{"resourceVersion":"10"},"items":[{"metadata":{"name":"web","namespace":"dev"}}]}`)
+	}))
+	defer server.Close()
+
+	be := newTestWatchBackend(server)
+	done := make(chan error)
+	go func() { done <- be.watch(podResource) }()
+	<-listing
+	old := be.watchOf("pods")
+	be.mutex.Lock()
+	be.stopWatches0("pods")
+	be.mutex.Unlock()
+	close(release)
+	require.Nil(<-done)
+	require.Empty(be.store.list("pods"), "stopped watch must not write to the store")
+	require.True(old.isClosed())
+
+	w := newResourceWatch(podResource, "")
+	be.setWatch(w.key(), w)
+	be.setWatch(w.key(), newResourceWatch(podResource, ""))
+	require.True(w.isClosed(), "replaced watch must be closed")
+}
+
+func Test_WatchPollsResourcesWithoutWatchVerb(t *testing.T) {
+	require := require.New(t)
+	defer func(d time.Duration) { watchPollInterval = d }(watchPollInterval)
+	watchPollInterval = 10 * time.Millisecond
+	server := newTestWatchServer(map[string]string{})
+	defer server.Close()
+
+	be := newTestWatchBackend(server.Server)
+	require.Nil(be.watch(resourceType{Name: "pods", APIPrefix: "api/v1", Namespace: true}))
+	require.Eventually(func() bool { return server.listCount() >= 3 }, 5*time.Second, 10*time.Millisecond)
+	require.False(server.watchedWith("10"), "resource must not be watched")
+	require.Equal(watchOnline, be.watchState("pods"))
+
+	// a list in progress must be finished before the server is closed
+	w := be.watchOf("pods")
+	w.close()
+	lists := server.listCount()
+	time.Sleep(10 * watchPollInterval)
+	require.LessOrEqual(server.listCount(), lists+1, "closed watch must stop polling")
+	// the poll loop has checked the closed watch for the last time, so watchPollInterval is restored after it
+	require.True(w.isClosed())
+}
+
+func Test_WatchListsInChunks(t *testing.T) {
+	require := require.New(t)
+	defer func(size int) { listChunkSize = size }(listChunkSize)
+	listChunkSize = 2
+	mutex := sync.Mutex{}
+	requests := []string{}
+	expired := false
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		requests = append(requests, r.URL.RawQuery)
+		if r.URL.Query().Get("limit") != "2" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch r.URL.Query().Get("continue") {
+		case "":
+			fmt.Fprint(w, `{"kind":"PodList","apiVersion":"v1","metadata":{"resourceVersion":"10","continue":"a/b","remainingItemCount":1},"items":[{"metadata":{"name":"p1","namespace":"dev"}},{"metadata":{"name":"p2","namespace":"dev"}}]}`)
+		case "a/b":
+			if !expired {
+				expired = true
+				w.WriteHeader(http.StatusGone)
+				return
+			}
+			fmt.Fprint(w, `{"kind":"PodList","apiVersion":"v1","metadata":{"resourceVersion":"10"},"items":[{"metadata":{"name":"p3","namespace":"dev"}}]}`)
+		}
+	}))
+	defer server.Close()
+
+	be := newTestWatchBackend(server)
+	w := newResourceWatch(podResource, "")
+	be.setWatch(w.key(), w)
+	require.Nil(be.list(w))
+	require.Equal([]string{"limit=2", "limit=2&continue=a%2Fb", "limit=2", "limit=2&continue=a%2Fb"}, requests, "list is started again when the continue token expired")
+	require.Len(be.store.list("pods"), 3)
+	require.Equal("10", w.getResourceVersion())
+	fetched, _ := be.listProgress("pods")
+	require.Equal(0, fetched, "no progress when the list is done")
+
+	w.setProgress(2, 3)
+	fetched, total := be.listProgress("pods")
+	require.Equal([]int{2, 3}, []int{fetched, total})
+}
+
+func Test_WatchBackoff(t *testing.T) {
+	require := require.New(t)
+	backoff := backoffType{initial: time.Second, max: 4 * time.Second}
+	for _, max := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second} {
+		delay := backoff.next()
+		require.True(delay >= max/2 && delay <= max, "delay %v not in [%v,%v]", delay, max/2, max)
+	}
+	backoff.reset()
+	require.True(backoff.next() <= time.Second)
+}
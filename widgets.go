@@ -268,6 +268,62 @@ func (w *searchWidget) Layout(g *gocui.Gui) error {
 	return nil
 }
 
+// promptWidget editable line, which is filled with text when it is shown. changed is called with the text while it is edited
+type promptWidget struct {
+	visible, active bool
+	name, title     string
+	x, y            int
+	w               int
+	text            string
+	reset           bool
+	changed         func(text string)
+	lastText        string
+}
+
+func newPromptWidget(name string, x, y, w int) *promptWidget {
+	return &promptWidget{name: name, x: x, y: y, w: w}
+}
+
+func (w *promptWidget) show(title, text string) {
+	w.title, w.text = title, text
+	w.visible, w.active, w.reset = true, true, true
+}
+
+func (w *promptWidget) Layout(g *gocui.Gui) error {
+	if !w.visible {
+		g.DeleteView(w.name)
+		return nil
+	}
+	v, err := g.SetView(w.name, w.x, w.y, w.x+w.w, w.y+2)
+	if err != nil {
+		if err != gocui.ErrUnknownView {
+			return err
+		}
+	}
+	if w.active {
+		g.SetCurrentView(w.name)
+	}
+	if w.reset {
+		v.Clear()
+		v.SetOrigin(0, 0)
+		v.SetCursor(0, 0)
+		v.Write([]byte(w.text))
+		for range w.text {
+			v.MoveCursor(1, 0, true)
+		}
+		w.reset = false
+		w.lastText = w.text
+	}
+	if s := strings.TrimRight(v.Buffer(), "\n "); w.changed != nil && s != w.lastText {
+		w.lastText = s
+		w.changed(s)
+	}
+	v.Title = w.title
+	v.Frame = true
+	v.Editable = true
+	return nil
+}
+
 type textWidget struct {
 	visible, active, showPos bool
 	name, title, footer      string
@@ -454,6 +510,9 @@ type selWidget struct {
 	headerItem    interface{}
 	posFunc       func(w *selWidget, index int) (int, int, int, int)
 	limitFunc     func(w *selWidget) int
+	highlightFunc func(text string) string
+	markFunc      func(item interface{}) bool
+	markFgColor   gocui.Attribute
 }
 
 func newSelWidget(name string, x, y, wi, h int) *selWidget {
@@ -536,9 +595,12 @@ func (w *selWidget) Layout(g *gocui.Gui) error {
 			}
 			v.Frame = false
 			v.FgColor = w.tableFgColor
+			if w.markFunc != nil && w.markFunc(w.items[i]) {
+				v.FgColor = w.markFgColor
+			}
 
 			v.Clear()
-			fmt.Fprint(v, w.render(w.items[i]))
+			fmt.Fprint(v, w.renderItem(w.items[i]))
 			if i == w.selectedItem {
 				v.FgColor = v.FgColor | gocui.AttrReverse
 			}
@@ -555,7 +617,7 @@ func (w *selWidget) Layout(g *gocui.Gui) error {
 		v.Frame = false
 		v.FgColor = w.tableFgColor
 		v.Clear()
-		fmt.Fprint(v, w.render(w.items[w.selectedItem]))
+		fmt.Fprint(v, w.renderItem(w.items[w.selectedItem]))
 	}
 	return nil
 }
@@ -566,6 +628,14 @@ func (w *selWidget) render(arg interface{}) string {
 	return buf.String()
 }
 
+// renderItem renders an item, which the highlight function may mark up
+func (w *selWidget) renderItem(item interface{}) string {
+	if w.highlightFunc != nil {
+		return w.highlightFunc(w.render(item))
+	}
+	return w.render(item)
+}
+
 func (w *selWidget) nextSelectedItem() {
 	start := w.selectedPage * w.limitFunc(w)
 	switch {
@@ -586,6 +656,14 @@ func (w *selWidget) previousSelectedItem() {
 	}
 }
 
+func (w *selWidget) selectItem(i int) {
+	if i < 0 || i >= len(w.items) {
+		i = 0
+	}
+	w.selectedItem = i
+	w.selectedPage = i / w.limitFunc(w)
+}
+
 func (w *selWidget) pc() int {
 	c := len(w.items) / w.limitFunc(w)
 	m := len(w.items) % w.limitFunc(w)